@@ -0,0 +1,43 @@
+// Package buildinfo describes the running binary from the information the Go
+// toolchain embeds at build time.
+package buildinfo
+
+import (
+	"runtime/debug"
+)
+
+// buildTime is not recorded by the toolchain; release builds set it with
+//
+//	go build -ldflags "-X test/buildinfo.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var buildTime string
+
+type Info struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	CommitTime string `json:"commit_time"`
+	Modified   bool   `json:"modified"`
+	BuildTime  string `json:"build_time"`
+	GoVersion  string `json:"go_version"`
+}
+
+// Read returns the build information. Fields the toolchain did not record,
+// such as the commit of a binary built outside a git checkout, are empty.
+func Read() Info {
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Info{BuildTime: buildTime}
+	}
+
+	info := Info{Version: build.Main.Version, BuildTime: buildTime, GoVersion: build.GoVersion}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			info.CommitTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
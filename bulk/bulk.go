@@ -0,0 +1,268 @@
+package bulk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"test/logging"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch value {
+	case "", "csv", "text/csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl", "json", "application/x-ndjson", "application/json":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("unsupported format %q", value)
+}
+
+func (f Format) ContentType() string {
+	if f == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+type Mode string
+
+const (
+	// ModeAtomic commits nothing unless every row is valid.
+	ModeAtomic Mode = "atomic"
+	// ModeBestEffort commits every valid row and reports the rest.
+	ModeBestEffort Mode = "best-effort"
+)
+
+func ParseMode(value string) (Mode, error) {
+	switch value {
+	case "", string(ModeAtomic):
+		return ModeAtomic, nil
+	case string(ModeBestEffort):
+		return ModeBestEffort, nil
+	}
+	return "", fmt.Errorf("unsupported mode %q", value)
+}
+
+type Options struct {
+	Format Format
+	Mode   Mode
+	DryRun bool
+	UserID uint
+}
+
+const maxReportedErrors = 1000
+
+type RowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+type Report struct {
+	Kind      string     `json:"kind"`
+	Format    Format     `json:"format"`
+	Mode      Mode       `json:"mode"`
+	DryRun    bool       `json:"dry_run"`
+	Total     int        `json:"total"`
+	Valid     int        `json:"valid"`
+	Failed    int        `json:"failed"`
+	Imported  int        `json:"imported"`
+	Committed bool       `json:"committed"`
+	Errors    []RowError `json:"errors"`
+	Truncated bool       `json:"errors_truncated,omitempty"`
+}
+
+func (r *Report) addError(row int, err error) {
+	r.Failed++
+	if len(r.Errors) >= maxReportedErrors {
+		r.Truncated = true
+		return
+	}
+	r.Errors = append(r.Errors, RowError{Row: row, Message: err.Error()})
+}
+
+var ErrUnknownKind = errors.New("unknown import kind")
+
+// errNotSaved replaces database errors in the report, which is returned to
+// the caller; the cause is logged instead.
+var errNotSaved = errors.New("row could not be saved")
+
+// InputError reports input that cannot be read any further. Row is the data
+// row at which reading stopped, or 0 for the CSV header.
+type InputError struct {
+	Row     int
+	Message string
+	err     error
+}
+
+func (e *InputError) Error() string {
+	if e.Row == 0 {
+		return "header: " + e.Message
+	}
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.err
+}
+
+type row interface {
+	fromCSV(record map[string]string) error
+	validate(tx *gorm.DB) error
+	create(tx *gorm.DB, userID uint) error
+}
+
+// committer is implemented by rows with side effects, such as metrics, that
+// must only happen once the row has been committed.
+type committer interface {
+	committed()
+}
+
+type kind struct {
+	newRow  func() row
+	columns []string
+	export  func(db *gorm.DB, write func(csv []string, json interface{}) error) error
+}
+
+var kinds = map[string]kind{
+	"quests":   questKind,
+	"uoms":     uomKind,
+	"products": productKind,
+}
+
+func Kinds() []string {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Import reads rows one at a time from r so arbitrarily large files can be
+// processed. In atomic and dry-run modes all rows share one transaction with
+// a savepoint per row, so a failing row does not poison the rest.
+func Import(db *gorm.DB, kindName string, r io.Reader, opts Options) (*Report, error) {
+	k, ok := kinds[kindName]
+	if !ok {
+		return nil, ErrUnknownKind
+	}
+
+	reader, err := newRecordReader(opts.Format, r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Kind: kindName, Format: opts.Format, Mode: opts.Mode, DryRun: opts.DryRun, Errors: []RowError{}}
+
+	transactional := opts.DryRun || opts.Mode == ModeAtomic
+	tx := db
+	var pending []committer
+	if transactional {
+		tx = db.Begin()
+		if tx.Error != nil {
+			return nil, tx.Error
+		}
+	}
+
+	for {
+		current := k.newRow()
+		err := reader.next(current)
+		if err == io.EOF {
+			break
+		}
+		report.Total++
+
+		var fatal *fatalError
+		if errors.As(err, &fatal) {
+			if transactional {
+				tx.Rollback()
+			}
+			return nil, &InputError{Row: report.Total, Message: describeReadError(fatal.err), err: fatal.err}
+		}
+		if err == nil {
+			err = current.validate(tx)
+		}
+		if err == nil {
+			if err = createRow(tx, current, opts.UserID, transactional); err != nil {
+				logging.Warn("Import row not saved", zap.String("kind", kindName), zap.Int("row", report.Total), zap.Error(err))
+				err = errNotSaved
+			}
+		}
+		if err != nil {
+			report.addError(report.Total, err)
+			continue
+		}
+		report.Valid++
+		if c, ok := current.(committer); ok {
+			if transactional {
+				pending = append(pending, c)
+			} else {
+				c.committed()
+			}
+		}
+	}
+
+	if !transactional {
+		report.Imported = report.Valid
+		report.Committed = report.Imported > 0
+		return report, nil
+	}
+
+	if opts.DryRun || report.Failed > 0 {
+		return report, tx.Rollback().Error
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	for _, c := range pending {
+		c.committed()
+	}
+	report.Imported = report.Valid
+	report.Committed = true
+	return report, nil
+}
+
+func createRow(tx *gorm.DB, current row, userID uint, savepoint bool) error {
+	if !savepoint {
+		return current.create(tx, userID)
+	}
+	if err := tx.SavePoint("bulk_row").Error; err != nil {
+		return err
+	}
+	if err := current.create(tx, userID); err != nil {
+		tx.RollbackTo("bulk_row")
+		return err
+	}
+	return nil
+}
+
+// Export writes every row of the given kind to w, loading them in batches so
+// memory use stays flat regardless of table size.
+func Export(db *gorm.DB, kindName string, format Format, w io.Writer) error {
+	k, ok := kinds[kindName]
+	if !ok {
+		return ErrUnknownKind
+	}
+
+	writer, err := newRecordWriter(format, w, k.columns)
+	if err != nil {
+		return err
+	}
+
+	if err := k.export(db, writer.write); err != nil {
+		return err
+	}
+	return writer.flush()
+}
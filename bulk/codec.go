@@ -0,0 +1,177 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	maxLineBytes = 1 << 20
+	flushEvery   = 100
+)
+
+// fatalError marks input that cannot be read any further, as opposed to a
+// single bad row.
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+// describeReadError says what is wrong with the input at the point err was
+// returned, without echoing errors from the underlying reader.
+func describeReadError(err error) string {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Sprintf("column %d: %v", parseErr.Column, parseErr.Err)
+	}
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Sprintf("line is longer than %d bytes", maxLineBytes)
+	}
+	return "input could not be read"
+}
+
+type recordReader interface {
+	next(into row) error
+}
+
+func newRecordReader(format Format, r io.Reader) (recordReader, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.TrimLeadingSpace = true
+		header, err := reader.Read()
+		if err == io.EOF {
+			return &csvReader{reader: reader}, nil
+		}
+		if err != nil {
+			return nil, &InputError{Message: describeReadError(err), err: err}
+		}
+		for i := range header {
+			header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		}
+		return &csvReader{reader: reader, header: header}, nil
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+		return &ndjsonReader{scanner: scanner}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func (c *csvReader) next(into row) error {
+	if c.header == nil {
+		return io.EOF
+	}
+
+	values, err := c.reader.Read()
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			return fmt.Errorf("expected %d columns, got %d", len(c.header), len(values))
+		}
+		return &fatalError{err: err}
+	}
+
+	record := make(map[string]string, len(c.header))
+	for i, name := range c.header {
+		record[name] = strings.TrimSpace(values[i])
+	}
+	return into.fromCSV(record)
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+}
+
+func (n *ndjsonReader) next(into row) error {
+	for n.scanner.Scan() {
+		line := bytes.TrimSpace(n.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(line, into); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		return nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return &fatalError{err: err}
+	}
+	return io.EOF
+}
+
+type flusher interface {
+	Flush()
+}
+
+type recordWriter struct {
+	format  Format
+	out     io.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	written int
+}
+
+func newRecordWriter(format Format, w io.Writer, columns []string) (*recordWriter, error) {
+	writer := &recordWriter{format: format, out: w}
+	switch format {
+	case FormatCSV:
+		writer.csv = csv.NewWriter(w)
+		if err := writer.csv.Write(columns); err != nil {
+			return nil, err
+		}
+	case FormatNDJSON:
+		writer.json = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	return writer, nil
+}
+
+func (w *recordWriter) write(csvRecord []string, jsonRecord interface{}) error {
+	var err error
+	if w.csv != nil {
+		err = w.csv.Write(csvRecord)
+	} else {
+		err = w.json.Encode(jsonRecord)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.written++
+	if w.written%flushEvery == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *recordWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if f, ok := w.out.(flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
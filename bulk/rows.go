@@ -0,0 +1,302 @@
+package bulk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"test/metrics"
+	"test/models"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const exportBatchSize = 500
+
+var validate = validator.New()
+
+func validateStruct(value interface{}) error {
+	err := validate.Struct(value)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+	messages := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		messages = append(messages, fmt.Sprintf("%s failed %s", strings.ToLower(fieldErr.Field()), fieldErr.Tag()))
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+func parseInt(record map[string]string, column string) (int, error) {
+	raw := record[column]
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", column)
+	}
+	return value, nil
+}
+
+func parseOptionalID(record map[string]string, column string) (*uint, error) {
+	raw := record[column]
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a positive integer", column)
+	}
+	id := uint(value)
+	return &id, nil
+}
+
+func formatOptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+type questRow struct {
+	ID          uint             `json:"id,omitempty"`
+	Title       string           `json:"title" validate:"required"`
+	Description string           `json:"description" validate:"required"`
+	Reward      int              `json:"reward" validate:"required"`
+	Difficulty  string           `json:"difficulty"`
+	CategoryID  *uint            `json:"category_id"`
+	Tags        []string         `json:"tags"`
+	Geofence    *models.Geofence `json:"geofence,omitempty"`
+}
+
+var questKind = kind{
+	newRow:  func() row { return &questRow{} },
+	columns: []string{"id", "title", "description", "reward", "difficulty", "category_id", "tags", "geofence"},
+	export: func(db *gorm.DB, write func([]string, interface{}) error) error {
+		var batch []models.Quest
+		return db.Preload("Tags").Order("id").FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, quest := range batch {
+				current := questRow{
+					ID:          quest.ID,
+					Title:       quest.Title,
+					Description: quest.Description,
+					Reward:      quest.Reward,
+					Difficulty:  string(quest.Difficulty),
+					CategoryID:  quest.CategoryID,
+					Tags:        []string{},
+				}
+				for _, tag := range quest.Tags {
+					current.Tags = append(current.Tags, tag.Name)
+				}
+
+				geofence := ""
+				if quest.Geofence.Enabled() {
+					fence := quest.Geofence
+					current.Geofence = &fence
+					encoded, err := json.Marshal(fence)
+					if err != nil {
+						return err
+					}
+					geofence = string(encoded)
+				}
+
+				record := []string{
+					strconv.FormatUint(uint64(current.ID), 10),
+					current.Title,
+					current.Description,
+					strconv.Itoa(current.Reward),
+					current.Difficulty,
+					formatOptionalID(current.CategoryID),
+					strings.Join(current.Tags, ";"),
+					geofence,
+				}
+				if err := write(record, current); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	},
+}
+
+func (q *questRow) fromCSV(record map[string]string) error {
+	var err error
+	q.Title = record["title"]
+	q.Description = record["description"]
+	q.Difficulty = record["difficulty"]
+	if q.Reward, err = parseInt(record, "reward"); err != nil {
+		return err
+	}
+	if q.CategoryID, err = parseOptionalID(record, "category_id"); err != nil {
+		return err
+	}
+	if raw := record["tags"]; raw != "" {
+		q.Tags = strings.Split(raw, ";")
+	}
+	if raw := record["geofence"]; raw != "" {
+		q.Geofence = &models.Geofence{}
+		if err := json.Unmarshal([]byte(raw), q.Geofence); err != nil {
+			return errors.New("geofence must be a JSON object")
+		}
+	}
+	return nil
+}
+
+func (q *questRow) validate(tx *gorm.DB) error {
+	if err := validateStruct(q); err != nil {
+		return err
+	}
+
+	if q.Difficulty == "" {
+		q.Difficulty = string(models.DifficultyMedium)
+	}
+	if !models.Difficulty(q.Difficulty).Valid() {
+		return fmt.Errorf("unknown difficulty %q", q.Difficulty)
+	}
+
+	if q.Geofence != nil {
+		if err := q.Geofence.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if q.CategoryID != nil {
+		var count int64
+		if err := tx.Model(&models.Category{}).Where("id = ?", *q.CategoryID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("category %d not found", *q.CategoryID)
+		}
+	}
+	return nil
+}
+
+func (q *questRow) create(tx *gorm.DB, userID uint) error {
+	tags, err := models.FindOrCreateTags(tx, q.Tags)
+	if err != nil {
+		return err
+	}
+
+	quest := &models.Quest{
+		Title:       q.Title,
+		Description: q.Description,
+		Reward:      q.Reward,
+		UserID:      userID,
+		Difficulty:  models.Difficulty(q.Difficulty),
+		CategoryID:  q.CategoryID,
+		Tags:        tags,
+	}
+	if q.Geofence != nil {
+		quest.Geofence = *q.Geofence
+	}
+	return tx.Create(quest).Error
+}
+
+type uomRow struct {
+	ID   uint   `json:"id,omitempty"`
+	Name string `json:"name" validate:"required"`
+}
+
+var uomKind = kind{
+	newRow:  func() row { return &uomRow{} },
+	columns: []string{"id", "name"},
+	export: func(db *gorm.DB, write func([]string, interface{}) error) error {
+		var batch []models.Uom
+		return db.Order("id").FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, uom := range batch {
+				current := uomRow{ID: uom.ID, Name: uom.Name}
+				if err := write([]string{strconv.FormatUint(uint64(uom.ID), 10), uom.Name}, current); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	},
+}
+
+func (u *uomRow) fromCSV(record map[string]string) error {
+	u.Name = record["name"]
+	return nil
+}
+
+func (u *uomRow) validate(tx *gorm.DB) error {
+	return validateStruct(u)
+}
+
+func (u *uomRow) create(tx *gorm.DB, userID uint) error {
+	return tx.Create(&models.Uom{Name: u.Name, UserID: userID}).Error
+}
+
+type productRow struct {
+	ID    uint   `json:"id,omitempty"`
+	Name  string `json:"name" validate:"required"`
+	Qty   int    `json:"qty" validate:"gte=0"`
+	UomID uint   `json:"uom_id" validate:"required"`
+}
+
+var productKind = kind{
+	newRow:  func() row { return &productRow{} },
+	columns: []string{"id", "name", "qty", "uom_id"},
+	export: func(db *gorm.DB, write func([]string, interface{}) error) error {
+		var batch []models.Product
+		return db.Order("id").FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, product := range batch {
+				current := productRow{ID: product.ID, Name: product.Name, Qty: product.Qty, UomID: product.UomID}
+				record := []string{
+					strconv.FormatUint(uint64(product.ID), 10),
+					product.Name,
+					strconv.Itoa(product.Qty),
+					strconv.FormatUint(uint64(product.UomID), 10),
+				}
+				if err := write(record, current); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	},
+}
+
+func (p *productRow) fromCSV(record map[string]string) error {
+	var err error
+	p.Name = record["name"]
+	if p.Qty, err = parseInt(record, "qty"); err != nil {
+		return err
+	}
+	uomID, err := parseOptionalID(record, "uom_id")
+	if err != nil {
+		return err
+	}
+	if uomID != nil {
+		p.UomID = *uomID
+	}
+	return nil
+}
+
+func (p *productRow) validate(tx *gorm.DB) error {
+	if err := validateStruct(p); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.Model(&models.Uom{}).Where("id = ?", p.UomID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("uom %d not found", p.UomID)
+	}
+	return nil
+}
+
+func (p *productRow) create(tx *gorm.DB, userID uint) error {
+	return tx.Create(&models.Product{Name: p.Name, Qty: p.Qty, UomID: p.UomID, UserID: userID}).Error
+}
+
+func (p *productRow) committed() {
+	metrics.StockMoved(p.Qty)
+}
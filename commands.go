@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"test/bulk"
+	"test/config"
+	"test/logging"
+	"test/migrations"
+	"test/models"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func runCommand(name string, args []string) int {
+	switch name {
+	case "import":
+		return runImport(args)
+	case "export":
+		return runExport(args)
+	case "migrate":
+		return runMigrate(args)
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+	return 2
+}
+
+// connectDatabase connects using the same configuration as the server,
+// without command-line overrides.
+func connectDatabase() bool {
+	cfg, err := config.Load(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	if err := logging.Configure(cfg.Logging); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	if err := models.ConnectDatabase(cfg.Database); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	return true
+}
+
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+func formatFromPath(path string) string {
+	if strings.HasSuffix(path, ".ndjson") || strings.HasSuffix(path, ".jsonl") {
+		return "ndjson"
+	}
+	return "csv"
+}
+
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "csv or ndjson (default: from file extension)")
+	mode := flags.String("mode", string(bulk.ModeAtomic), "atomic or best-effort")
+	dryRun := flags.Bool("dry-run", false, "validate without writing")
+	userID := flags.Uint("user", 0, "owner user ID for imported rows (required)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: import [flags] <%s> [file|-]\n", strings.Join(bulk.Kinds(), "|"))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	if *userID == 0 {
+		fmt.Fprintln(os.Stderr, "-user is required")
+		flags.Usage()
+		return 2
+	}
+
+	kind, path := flags.Arg(0), flags.Arg(1)
+	if *format == "" {
+		*format = formatFromPath(path)
+	}
+
+	parsedFormat, err := bulk.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	parsedMode, err := bulk.ParseMode(*mode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	input, err := openInput(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer input.Close()
+
+	if !connectDatabase() {
+		return 1
+	}
+	if err := models.DB.Where("id = ?", *userID).First(&models.Users{}).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Fprintf(os.Stderr, "user %d not found\n", *userID)
+		return 1
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	report, err := bulk.Import(models.DB, kind, input, bulk.Options{
+		Format: parsedFormat,
+		Mode:   parsedMode,
+		DryRun: *dryRun,
+		UserID: *userID,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import failed:", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "", "csv or ndjson (default: from file extension)")
+	output := flags.String("o", "-", "output file")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: export [flags] <%s>\n", strings.Join(bulk.Kinds(), "|"))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	if *format == "" {
+		*format = formatFromPath(*output)
+	}
+	parsedFormat, err := bulk.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	out := os.Stdout
+	if *output != "-" {
+		out, err = os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer out.Close()
+	}
+
+	if !connectDatabase() {
+		return 1
+	}
+
+	if err := bulk.Export(models.DB, flags.Arg(0), parsedFormat, out); err != nil {
+		fmt.Fprintln(os.Stderr, "export failed:", err)
+		return 1
+	}
+	return 0
+}
+
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dir := flags.String("dir", migrations.Dir, "directory new migrations are created in")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: migrate [flags] up [N] | down [N] | status | create <name>")
+		fmt.Fprintln(flags.Output(), "  up applies all pending migrations unless N is given; down reverts one unless N is given")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	action := flags.Arg(0)
+	if action == "create" {
+		if flags.NArg() != 2 {
+			flags.Usage()
+			return 2
+		}
+		paths, err := migrations.Create(*dir, flags.Arg(1))
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	steps := 0
+	if action == "down" {
+		steps = 1
+	}
+	if flags.NArg() > 1 {
+		n, err := strconv.Atoi(flags.Arg(1))
+		if err != nil || n < 1 || flags.NArg() > 2 || action == "status" {
+			flags.Usage()
+			return 2
+		}
+		steps = n
+	}
+	if action != "up" && action != "down" && action != "status" {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := logging.Configure(cfg.Logging); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logging.Close()
+	cfg.Database.AutoMigrate = false
+	if err := models.ConnectDatabase(cfg.Database); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer models.CloseDatabase()
+
+	migrator, err := models.Migrator(models.DB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	var done []migrations.Migration
+	switch action {
+	case "up":
+		done, err = migrator.Up(ctx, steps)
+	case "down":
+		done, err = migrator.Down(ctx, steps)
+	case "status":
+		return printMigrationStatus(ctx, migrator)
+	}
+	for _, migration := range done {
+		fmt.Println(action, migration)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate failed:", err)
+		return 1
+	}
+	if len(done) == 0 {
+		fmt.Println("nothing to do")
+	}
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, migrator *migrations.Migrator) int {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "VERSION\tNAME\tAPPLIED\tNOTE")
+	for _, status := range statuses {
+		applied, note := "pending", ""
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Format(time.RFC3339)
+		}
+		if status.Modified {
+			note = "modified since applied"
+		}
+		if status.Unknown {
+			note = "not in this binary"
+		}
+		fmt.Fprintf(out, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, applied, note)
+	}
+	out.Flush()
+	return 0
+}
@@ -0,0 +1,361 @@
+// Package config loads the server configuration from, in increasing order of
+// precedence, built-in defaults, a YAML or TOML file, environment variables
+// and command-line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Lockout  LockoutConfig  `yaml:"lockout" toml:"lockout"`
+	Logging  LoggingConfig  `yaml:"logging" toml:"logging"`
+	Mail     MailConfig     `yaml:"mail" toml:"mail"`
+	OIDC     OIDCConfig     `yaml:"oidc" toml:"oidc"`
+}
+
+type ServerConfig struct {
+	Host string `yaml:"host" toml:"host"`
+	Port int    `yaml:"port" toml:"port"`
+	// BaseURL is the public address used in emailed links and OIDC
+	// redirects. It defaults to http://localhost:<port>.
+	BaseURL string `yaml:"base_url" toml:"base_url"`
+	// ShutdownTimeout bounds how long in-flight requests and workers get to
+	// finish after SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+func (s ServerConfig) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+type AuthConfig struct {
+	// JWTSecret signs session tokens and the other signed values the server
+	// hands out, such as verification links.
+	JWTSecret  string        `yaml:"jwt_secret" toml:"jwt_secret"`
+	SessionTTL time.Duration `yaml:"session_ttl" toml:"session_ttl"`
+}
+
+type DatabaseConfig struct {
+	// Driver is "postgres" or "sqlite". SQLite is meant for local
+	// development and tests; it only uses Path.
+	Driver string `yaml:"driver" toml:"driver"`
+	// Path is the SQLite database file, or ":memory:" for a database that
+	// lives as long as the process.
+	Path string `yaml:"path" toml:"path"`
+	// URL, when set, is used as the postgres DSN as-is and the other
+	// connection fields are ignored.
+	URL      string `yaml:"url" toml:"url"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode"`
+	// AutoMigrate applies pending migrations when the server starts. Turn it
+	// off to run "migrate up" as a separate deploy step.
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate"`
+}
+
+// DSN returns the connection string for the postgres driver.
+func (d DatabaseConfig) DSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+
+	params := []string{
+		"host=" + quoteDSN(d.Host),
+		"port=" + strconv.Itoa(d.Port),
+		"user=" + quoteDSN(d.User),
+		"password=" + quoteDSN(d.Password),
+		"dbname=" + quoteDSN(d.Name),
+		"sslmode=" + quoteDSN(d.SSLMode),
+	}
+	return strings.Join(params, " ")
+}
+
+func quoteDSN(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + value + "'"
+}
+
+type LockoutConfig struct {
+	// Store is "memory" or "db"; the database store shares lockouts between
+	// instances.
+	Store string `yaml:"store" toml:"store"`
+}
+
+type LoggingConfig struct {
+	// Level is debug, info, warn or error. Admins can change it while the
+	// server runs.
+	Level string `yaml:"level" toml:"level"`
+	// Format is "json" or "console", a human-readable format for
+	// development.
+	Format string `yaml:"format" toml:"format"`
+	// Output is "stdout", "stderr" or the path of a file, which is rotated
+	// once it reaches MaxSizeMB.
+	Output     string `yaml:"output" toml:"output"`
+	MaxSizeMB  int    `yaml:"max_size_mb" toml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups" toml:"max_backups"`
+	MaxAgeDays int    `yaml:"max_age_days" toml:"max_age_days"`
+	// Each second the first SampleInitial entries with the same level and
+	// message are logged, then every SampleThereafter-th. A zero
+	// SampleInitial turns sampling off.
+	SampleInitial    int `yaml:"sample_initial" toml:"sample_initial"`
+	SampleThereafter int `yaml:"sample_thereafter" toml:"sample_thereafter"`
+	// RedactFields masks fields whose name ends in one of these, ignoring
+	// case and separators, so "token" also covers "mfa_token".
+	RedactFields []string `yaml:"redact_fields" toml:"redact_fields"`
+	// RedactAllow exempts field names, or the value patterns jwt, bearer,
+	// api_key and email, from redaction while debugging.
+	RedactAllow []string `yaml:"redact_allow" toml:"redact_allow"`
+}
+
+const minJWTSecretLength = 32
+
+type MailConfig struct {
+	// Driver is "smtp", "file", which writes each message to Dir for local
+	// development, or "memory".
+	Driver string     `yaml:"driver" toml:"driver"`
+	From   string     `yaml:"from" toml:"from"`
+	Dir    string     `yaml:"dir" toml:"dir"`
+	SMTP   SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+type OIDCConfig struct {
+	// Providers maps the name used in /auth/oidc/{name}/... to its settings.
+	Providers map[string]OIDCProviderConfig `yaml:"providers" toml:"providers"`
+}
+
+type OIDCProviderConfig struct {
+	Issuer       string `yaml:"issuer" toml:"issuer"`
+	ClientID     string `yaml:"client_id" toml:"client_id"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+	// Scopes defaults to openid, email and profile.
+	Scopes []string `yaml:"scopes" toml:"scopes"`
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Host:            "0.0.0.0",
+			Port:            8008,
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Auth: AuthConfig{
+			SessionTTL: 6 * time.Hour,
+		},
+		Database: DatabaseConfig{
+			Driver:      "postgres",
+			Path:        "quests.db",
+			Host:        "localhost",
+			Port:        5432,
+			SSLMode:     "prefer",
+			AutoMigrate: true,
+		},
+		Lockout: LockoutConfig{
+			Store: "memory",
+		},
+		Logging: LoggingConfig{
+			Level:            "info",
+			Format:           "json",
+			Output:           "stderr",
+			MaxSizeMB:        100,
+			MaxBackups:       5,
+			MaxAgeDays:       30,
+			SampleInitial:    100,
+			SampleThereafter: 100,
+			RedactFields:     []string{"token", "password", "secret", "email", "authorization", "cookie"},
+		},
+		Mail: MailConfig{
+			Driver: "file",
+			From:   "no-reply@localhost",
+			Dir:    "mail_outbox",
+			SMTP:   SMTPConfig{Port: 587},
+		},
+	}
+}
+
+var (
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	logFormats = []string{"json", "console"}
+)
+
+var mailDrivers = []string{"smtp", "file", "memory"}
+
+var oidcProviderName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Validate reports every invalid setting at once, naming the file key and
+// environment variable that control it.
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		problem("server.port (PORT) must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		problem("server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive, got %s", c.Server.ShutdownTimeout)
+	}
+	if c.Server.BaseURL != "" {
+		parsed, err := url.Parse(c.Server.BaseURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problem("server.base_url (BASE_URL) must be an absolute http or https URL, got %q", c.Server.BaseURL)
+		}
+	}
+
+	if c.Auth.JWTSecret == "" {
+		problem("auth.jwt_secret (JWT_SECRET) is required")
+	} else if len(c.Auth.JWTSecret) < minJWTSecretLength {
+		problem("auth.jwt_secret (JWT_SECRET) must be at least %d characters", minJWTSecretLength)
+	}
+	if c.Auth.SessionTTL <= 0 {
+		problem("auth.session_ttl (SESSION_TTL) must be positive, got %s", c.Auth.SessionTTL)
+	}
+
+	switch c.Database.Driver {
+	case "sqlite":
+		if c.Database.Path == "" {
+			problem("database.path (DB_PATH) is required when database.driver is sqlite")
+		}
+	case "postgres":
+		c.validatePostgres(problem)
+	default:
+		problem("database.driver (DB_DRIVER) must be postgres or sqlite, got %q", c.Database.Driver)
+	}
+
+	if c.Lockout.Store != "memory" && c.Lockout.Store != "db" {
+		problem("lockout.store (LOCKOUT_STORE) must be memory or db, got %q", c.Lockout.Store)
+	}
+
+	c.validateLogging(problem)
+	c.validateMail(problem)
+	c.validateOIDC(problem)
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (c *Config) validatePostgres(problem func(string, ...interface{})) {
+	if c.Database.URL == "" {
+		if c.Database.Host == "" {
+			problem("database.host (DB_HOST) is required unless database.url (DATABASE_URL) is set")
+		}
+		if c.Database.Port < 1 || c.Database.Port > 65535 {
+			problem("database.port (DB_PORT) must be between 1 and 65535, got %d", c.Database.Port)
+		}
+		if c.Database.Name == "" {
+			problem("database.name (DB_NAME) is required unless database.url (DATABASE_URL) is set")
+		}
+		if !contains(sslModes, c.Database.SSLMode) {
+			problem("database.sslmode (DB_SSLMODE) must be one of %s, got %q", strings.Join(sslModes, ", "), c.Database.SSLMode)
+		}
+	}
+}
+
+func (c *Config) validateLogging(problem func(string, ...interface{})) {
+	if !contains(logLevels, c.Logging.Level) {
+		problem("logging.level (LOG_LEVEL) must be one of %s, got %q", strings.Join(logLevels, ", "), c.Logging.Level)
+	}
+	if !contains(logFormats, c.Logging.Format) {
+		problem("logging.format (LOG_FORMAT) must be json or console, got %q", c.Logging.Format)
+	}
+	if c.Logging.Output == "" {
+		problem("logging.output (LOG_OUTPUT) is required")
+	}
+	if c.Logging.MaxSizeMB <= 0 {
+		problem("logging.max_size_mb (LOG_MAX_SIZE_MB) must be positive, got %d", c.Logging.MaxSizeMB)
+	}
+	if c.Logging.MaxBackups < 0 {
+		problem("logging.max_backups (LOG_MAX_BACKUPS) must not be negative, got %d", c.Logging.MaxBackups)
+	}
+	if c.Logging.MaxAgeDays < 0 {
+		problem("logging.max_age_days (LOG_MAX_AGE_DAYS) must not be negative, got %d", c.Logging.MaxAgeDays)
+	}
+	if c.Logging.SampleInitial < 0 {
+		problem("logging.sample_initial (LOG_SAMPLE_INITIAL) must not be negative, got %d", c.Logging.SampleInitial)
+	}
+	if c.Logging.SampleInitial > 0 && c.Logging.SampleThereafter <= 0 {
+		problem("logging.sample_thereafter (LOG_SAMPLE_THEREAFTER) must be positive when sampling, got %d", c.Logging.SampleThereafter)
+	}
+}
+
+func (c *Config) validateMail(problem func(string, ...interface{})) {
+	switch c.Mail.Driver {
+	case "smtp":
+		if c.Mail.SMTP.Host == "" {
+			problem("mail.smtp.host (SMTP_HOST) is required when mail.driver is smtp")
+		}
+		if c.Mail.SMTP.Port < 1 || c.Mail.SMTP.Port > 65535 {
+			problem("mail.smtp.port (SMTP_PORT) must be between 1 and 65535, got %d", c.Mail.SMTP.Port)
+		}
+	case "file":
+		if c.Mail.Dir == "" {
+			problem("mail.dir (MAIL_DIR) is required when mail.driver is file")
+		}
+	case "memory":
+	default:
+		problem("mail.driver (MAIL_DRIVER) must be one of %s, got %q", strings.Join(mailDrivers, ", "), c.Mail.Driver)
+	}
+	if c.Mail.From == "" {
+		problem("mail.from (MAIL_FROM) is required")
+	}
+}
+
+func (c *Config) validateOIDC(problem func(string, ...interface{})) {
+	names := make([]string, 0, len(c.OIDC.Providers))
+	for name := range c.OIDC.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		provider := c.OIDC.Providers[name]
+		env := "OIDC_" + strings.ToUpper(name) + "_"
+		if !oidcProviderName.MatchString(name) {
+			problem("oidc.providers.%s: names may only contain lowercase letters, digits, _ and -", name)
+			continue
+		}
+		parsed, err := url.Parse(provider.Issuer)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problem("oidc.providers.%s.issuer (%sISSUER) must be an absolute http or https URL, got %q", name, env, provider.Issuer)
+		}
+		if provider.ClientID == "" {
+			problem("oidc.providers.%s.client_id (%sCLIENT_ID) is required", name, env)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// binding ties one setting to its environment variable and, for settings
+// that are safe to pass on the command line, a flag.
+type binding struct {
+	env   string
+	flag  string
+	usage string
+	set   func(string) error
+}
+
+func bindings(c *Config) []binding {
+	return []binding{
+		{"HOST", "host", "address to listen on", setString(&c.Server.Host)},
+		{"PORT", "port", "port to listen on", setInt(&c.Server.Port)},
+		{"BASE_URL", "base-url", "public base URL of the server", setString(&c.Server.BaseURL)},
+		{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time allowed for a graceful shutdown, e.g. 15s", setDuration(&c.Server.ShutdownTimeout)},
+		{"JWT_SECRET", "", "", setString(&c.Auth.JWTSecret)},
+		{"SESSION_TTL", "session-ttl", "lifetime of login sessions, e.g. 6h", setDuration(&c.Auth.SessionTTL)},
+		{"DB_DRIVER", "db-driver", "database driver, postgres or sqlite", setString(&c.Database.Driver)},
+		{"DB_PATH", "db-path", "SQLite database file, or :memory:", setString(&c.Database.Path)},
+		{"DATABASE_URL", "", "", setString(&c.Database.URL)},
+		{"DB_HOST", "db-host", "database host", setString(&c.Database.Host)},
+		{"DB_PORT", "db-port", "database port", setInt(&c.Database.Port)},
+		{"DB_USER", "db-user", "database user", setString(&c.Database.User)},
+		{"DB_PASSWORD", "", "", setString(&c.Database.Password)},
+		{"DB_NAME", "db-name", "database name", setString(&c.Database.Name)},
+		{"DB_SSLMODE", "db-sslmode", "database sslmode", setString(&c.Database.SSLMode)},
+		{"DB_AUTO_MIGRATE", "db-auto-migrate", "apply pending migrations at startup, true or false", setBool(&c.Database.AutoMigrate)},
+		{"LOCKOUT_STORE", "lockout-store", "login lockout store, memory or db", setString(&c.Lockout.Store)},
+		{"LOG_LEVEL", "log-level", "minimum log level, debug, info, warn or error", setString(&c.Logging.Level)},
+		{"LOG_FORMAT", "log-format", "log format, json or console", setString(&c.Logging.Format)},
+		{"LOG_OUTPUT", "log-output", "stdout, stderr or a log file path", setString(&c.Logging.Output)},
+		{"LOG_MAX_SIZE_MB", "log-max-size-mb", "size at which the log file is rotated", setInt(&c.Logging.MaxSizeMB)},
+		{"LOG_MAX_BACKUPS", "log-max-backups", "rotated log files to keep, 0 for all", setInt(&c.Logging.MaxBackups)},
+		{"LOG_MAX_AGE_DAYS", "log-max-age-days", "days to keep rotated log files, 0 for no limit", setInt(&c.Logging.MaxAgeDays)},
+		{"LOG_SAMPLE_INITIAL", "log-sample-initial", "identical entries logged per second before sampling, 0 to disable", setInt(&c.Logging.SampleInitial)},
+		{"LOG_SAMPLE_THEREAFTER", "log-sample-thereafter", "log every Nth identical entry after that", setInt(&c.Logging.SampleThereafter)},
+		{"LOG_REDACT_FIELDS", "log-redact-fields", "comma-separated field names masked in logs", setList(&c.Logging.RedactFields)},
+		{"LOG_REDACT_ALLOW", "log-redact-allow", "comma-separated field names or patterns exempt from masking", setList(&c.Logging.RedactAllow)},
+		{"MAIL_DRIVER", "mail-driver", "mail driver, smtp, file or memory", setString(&c.Mail.Driver)},
+		{"MAIL_FROM", "mail-from", "sender address of outgoing mail", setString(&c.Mail.From)},
+		{"MAIL_DIR", "mail-dir", "directory the file mail driver writes to", setString(&c.Mail.Dir)},
+		{"SMTP_HOST", "smtp-host", "SMTP server host", setString(&c.Mail.SMTP.Host)},
+		{"SMTP_PORT", "smtp-port", "SMTP server port", setInt(&c.Mail.SMTP.Port)},
+		{"SMTP_USERNAME", "smtp-username", "SMTP user name", setString(&c.Mail.SMTP.Username)},
+		{"SMTP_PASSWORD", "", "", setString(&c.Mail.SMTP.Password)},
+	}
+}
+
+func setString(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+func setInt(p *int) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*p = parsed
+		return nil
+	}
+}
+
+func setBool(p *bool) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*p = parsed
+		return nil
+	}
+}
+
+// setList splits a comma-separated value, dropping empty items.
+func setList(p *[]string) func(string) error {
+	return func(value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*p = items
+		return nil
+	}
+}
+
+func setDuration(p *time.Duration) func(string) error {
+	return func(value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", value)
+		}
+		*p = parsed
+		return nil
+	}
+}
+
+// flagValue records a flag's raw value so it can be applied after the file
+// and environment, and only if it was actually given.
+type flagValue struct {
+	value string
+}
+
+func (f *flagValue) String() string {
+	return f.value
+}
+
+func (f *flagValue) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+// Load builds the configuration from the defaults, the file named by -config
+// or CONFIG_FILE, the environment and args, then validates it. Secrets can
+// only come from the file or the environment.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+	bound := bindings(&cfg)
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	values := map[string]*flagValue{}
+	for _, b := range bound {
+		if b.flag == "" {
+			continue
+		}
+		values[b.flag] = &flagValue{}
+		flags.Var(values[b.flag], b.flag, b.usage+" (env "+b.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	for _, b := range bound {
+		value := strings.TrimSpace(os.Getenv(b.env))
+		if value == "" {
+			continue
+		}
+		if err := b.set(value); err != nil {
+			return cfg, fmt.Errorf("environment variable %s: %v", b.env, err)
+		}
+	}
+
+	loadOIDCEnv(&cfg.OIDC)
+
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		for _, b := range bound {
+			if b.flag == f.Name && flagErr == nil {
+				if err := b.set(values[f.Name].value); err != nil {
+					flagErr = fmt.Errorf("flag -%s: %v", f.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+
+	if cfg.Server.BaseURL == "" {
+		cfg.Server.BaseURL = "http://localhost:" + strconv.Itoa(cfg.Server.Port)
+	}
+	cfg.Server.BaseURL = strings.TrimRight(cfg.Server.BaseURL, "/")
+
+	return cfg, cfg.Validate()
+}
+
+// loadOIDCEnv adds the providers named in OIDC_PROVIDERS (comma-separated)
+// and sets each one's OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and
+// _SCOPES over what the file configured.
+func loadOIDCEnv(c *OIDCConfig) {
+	var names []string
+	setList(&names)(os.Getenv("OIDC_PROVIDERS"))
+
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if c.Providers == nil {
+			c.Providers = map[string]OIDCProviderConfig{}
+		}
+		provider := c.Providers[name]
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		fields := map[string]*string{
+			"ISSUER":        &provider.Issuer,
+			"CLIENT_ID":     &provider.ClientID,
+			"CLIENT_SECRET": &provider.ClientSecret,
+		}
+		for suffix, field := range fields {
+			if value := strings.TrimSpace(os.Getenv(prefix + suffix)); value != "" {
+				*field = value
+			}
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); strings.TrimSpace(scopes) != "" {
+			provider.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+		c.Providers[name] = provider
+	}
+}
+
+// loadFile decodes a YAML or TOML file over cfg, chosen by extension.
+// Unknown keys are rejected so typos do not silently fall back to defaults.
+func loadFile(path string, cfg *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer file.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config file %s: %v", path, err)
+		}
+	case ".toml":
+		meta, err := toml.NewDecoder(file).Decode(cfg)
+		if err != nil {
+			return fmt.Errorf("config file %s: %v", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, key := range undecoded {
+				keys[i] = key.String()
+			}
+			return fmt.Errorf("config file %s: unknown keys %s", path, strings.Join(keys, ", "))
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported extension %q, use .yaml, .yml or .toml", path, ext)
+	}
+	return nil
+}
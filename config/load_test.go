@@ -0,0 +1,333 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"test/config"
+	"testing"
+)
+
+const testSecret = "ssssssssssssssssssssssssssssssss"
+
+// clearEnv unsets the variables these tests read, so the developer's own
+// environment does not leak into the results.
+func clearEnv(t *testing.T) {
+	for _, key := range []string{"CONFIG_FILE", "HOST", "PORT", "BASE_URL", "JWT_SECRET", "DB_DRIVER", "DB_PATH", "DB_NAME", "DATABASE_URL", "LOG_LEVEL", "OIDC_PROVIDERS"} {
+		t.Setenv(key, "")
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := `
+server:
+  port: 9000
+auth:
+  jwt_secret: ` + testSecret + `
+database:
+  driver: sqlite
+logging:
+  level: warn
+`
+	tomlFile := `
+[server]
+port = 9000
+
+[auth]
+jwt_secret = "` + testSecret + `"
+
+[database]
+driver = "sqlite"
+`
+
+	tests := []struct {
+		name      string
+		file      string
+		env       map[string]string
+		args      []string
+		wantPort  int
+		wantLevel string
+		wantBase  string
+	}{
+		{
+			name:      "defaults",
+			env:       map[string]string{"JWT_SECRET": testSecret, "DB_DRIVER": "sqlite"},
+			wantPort:  8008,
+			wantLevel: "info",
+			wantBase:  "http://localhost:8008",
+		},
+		{
+			name:      "yaml file over defaults",
+			file:      writeFile(t, "config.yaml", yamlFile),
+			wantPort:  9000,
+			wantLevel: "warn",
+			wantBase:  "http://localhost:9000",
+		},
+		{
+			name:      "toml file over defaults",
+			file:      writeFile(t, "config.toml", tomlFile),
+			wantPort:  9000,
+			wantLevel: "info",
+			wantBase:  "http://localhost:9000",
+		},
+		{
+			name:      "environment over file",
+			file:      writeFile(t, "config.yaml", yamlFile),
+			env:       map[string]string{"PORT": "9100", "LOG_LEVEL": "debug"},
+			wantPort:  9100,
+			wantLevel: "debug",
+			wantBase:  "http://localhost:9100",
+		},
+		{
+			name:      "flags over environment",
+			file:      writeFile(t, "config.yaml", yamlFile),
+			env:       map[string]string{"PORT": "9100", "LOG_LEVEL": "debug"},
+			args:      []string{"-port", "9200", "-log-level", "error"},
+			wantPort:  9200,
+			wantLevel: "error",
+			wantBase:  "http://localhost:9200",
+		},
+		{
+			name:      "base URL loses its trailing slash",
+			env:       map[string]string{"JWT_SECRET": testSecret, "DB_DRIVER": "sqlite", "BASE_URL": "https://quests.example.com/"},
+			wantPort:  8008,
+			wantLevel: "info",
+			wantBase:  "https://quests.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", tt.file}, args...)
+			}
+
+			cfg, err := config.Load(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != tt.wantPort {
+				t.Errorf("port = %d, want %d", cfg.Server.Port, tt.wantPort)
+			}
+			if cfg.Logging.Level != tt.wantLevel {
+				t.Errorf("log level = %q, want %q", cfg.Logging.Level, tt.wantLevel)
+			}
+			if cfg.Server.BaseURL != tt.wantBase {
+				t.Errorf("base URL = %q, want %q", cfg.Server.BaseURL, tt.wantBase)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileFromEnvironment(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.yml", "server:\n  port: 9300\nauth:\n  jwt_secret: "+testSecret+"\ndatabase:\n  driver: sqlite\n"))
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 9300 {
+		t.Errorf("port = %d, want 9300 from CONFIG_FILE", cfg.Server.Port)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{
+			name: "unknown yaml key",
+			file: writeFile(t, "config.yaml", "server:\n  prot: 9000\n"),
+			want: "field prot not found",
+		},
+		{
+			name: "unknown toml key",
+			file: writeFile(t, "config.toml", "[server]\nprot = 9000\n"),
+			want: "unknown keys server.prot",
+		},
+		{
+			name: "unsupported extension",
+			file: writeFile(t, "config.json", "{}"),
+			want: `unsupported extension ".json"`,
+		},
+		{
+			name: "malformed environment variable",
+			env:  map[string]string{"PORT": "x"},
+			want: `environment variable PORT: "x" is not an integer`,
+		},
+		{
+			name: "malformed flag",
+			args: []string{"-port", "x"},
+			want: `flag -port: "x" is not an integer`,
+		},
+		{
+			name: "secret has no flag",
+			args: []string{"-jwt-secret", testSecret},
+			want: "flag provided but not defined: -jwt-secret",
+		},
+		{
+			name: "invalid result",
+			env:  map[string]string{"JWT_SECRET": "short", "DB_DRIVER": "sqlite"},
+			want: "auth.jwt_secret (JWT_SECRET) must be at least 32 characters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", tt.file}, args...)
+			}
+
+			_, err := config.Load(args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() config.Config {
+		cfg := config.Default()
+		cfg.Auth.JWTSecret = testSecret
+		cfg.Database.Name = "quests"
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		change func(*config.Config)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			change: func(*config.Config) {},
+		},
+		{
+			name:   "port out of range",
+			change: func(c *config.Config) { c.Server.Port = 70000 },
+			want:   []string{"server.port (PORT) must be between 1 and 65535, got 70000"},
+		},
+		{
+			name:   "relative base URL",
+			change: func(c *config.Config) { c.Server.BaseURL = "quests.example.com" },
+			want:   []string{`server.base_url (BASE_URL) must be an absolute http or https URL, got "quests.example.com"`},
+		},
+		{
+			name:   "missing secret",
+			change: func(c *config.Config) { c.Auth.JWTSecret = "" },
+			want:   []string{"auth.jwt_secret (JWT_SECRET) is required"},
+		},
+		{
+			name:   "short secret",
+			change: func(c *config.Config) { c.Auth.JWTSecret = "short" },
+			want:   []string{"auth.jwt_secret (JWT_SECRET) must be at least 32 characters"},
+		},
+		{
+			name:   "unknown driver",
+			change: func(c *config.Config) { c.Database.Driver = "mysql" },
+			want:   []string{`database.driver (DB_DRIVER) must be postgres or sqlite, got "mysql"`},
+		},
+		{
+			name:   "postgres without a database",
+			change: func(c *config.Config) { c.Database.Host, c.Database.Name = "", "" },
+			want: []string{
+				"database.host (DB_HOST) is required unless database.url (DATABASE_URL) is set",
+				"database.name (DB_NAME) is required unless database.url (DATABASE_URL) is set",
+			},
+		},
+		{
+			name: "postgres URL instead of fields",
+			change: func(c *config.Config) {
+				c.Database.URL, c.Database.Host, c.Database.Name = "postgres://localhost/quests", "", ""
+			},
+		},
+		{
+			name:   "sqlite without a path",
+			change: func(c *config.Config) { c.Database.Driver, c.Database.Path = "sqlite", "" },
+			want:   []string{"database.path (DB_PATH) is required when database.driver is sqlite"},
+		},
+		{
+			name:   "unknown lockout store",
+			change: func(c *config.Config) { c.Lockout.Store = "redis" },
+			want:   []string{`lockout.store (LOCKOUT_STORE) must be memory or db, got "redis"`},
+		},
+		{
+			name:   "unknown log level",
+			change: func(c *config.Config) { c.Logging.Level = "trace" },
+			want:   []string{`logging.level (LOG_LEVEL) must be one of debug, info, warn, error, got "trace"`},
+		},
+		{
+			name:   "unknown mail driver",
+			change: func(c *config.Config) { c.Mail.Driver = "sendmail" },
+			want:   []string{`mail.driver (MAIL_DRIVER) must be one of smtp, file, memory, got "sendmail"`},
+		},
+		{
+			name: "every problem at once",
+			change: func(c *config.Config) {
+				c.Server.Port = 0
+				c.Auth.JWTSecret = ""
+				c.Logging.Level = "trace"
+			},
+			want: []string{
+				"server.port (PORT) must be between 1 and 65535, got 0",
+				"auth.jwt_secret (JWT_SECRET) is required",
+				`logging.level (LOG_LEVEL) must be one of debug, info, warn, error, got "trace"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.change(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %q", tt.want)
+			}
+			if !strings.HasPrefix(err.Error(), "invalid configuration:\n  ") {
+				t.Errorf("Validate() = %q, want it to start with the summary line", err)
+			}
+			problems := strings.Split(strings.TrimPrefix(err.Error(), "invalid configuration:\n  "), "\n  ")
+			if len(problems) != len(tt.want) {
+				t.Errorf("Validate() reported %q, want %q", problems, tt.want)
+				return
+			}
+			for i, want := range tt.want {
+				if problems[i] != want {
+					t.Errorf("problem %d = %q, want %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"test/logging"
+	"test/middleware"
+	"test/models"
+	"test/repository"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const adminPageSize = 25
+
+var adminNotices = map[string]string{
+	"user-saved":      "User updated",
+	"points-adjusted": "Points adjusted",
+	"quest-updated":   "Quest status updated",
+	"uom-created":     "Unit created",
+	"uom-saved":       "Unit updated",
+	"uom-deleted":     "Unit deleted",
+	"product-created": "Product created",
+	"product-saved":   "Product updated",
+	"product-deleted": "Product deleted",
+}
+
+var adminErrors = map[string]string{
+	"invalid-input":   "Please check the form and try again",
+	"reason-required": "A reason is required for this change",
+	"username-taken":  "Username already exists",
+	"email-taken":     "Email already exists",
+	"own-role":        "You cannot change your own role",
+	"uom-in-use":      "This unit is still used by products",
+	"save-failed":     "The change could not be saved",
+}
+
+// adminPage is embedded in the data of every admin template.
+type adminPage struct {
+	Admin   models.Users
+	CSRF    string
+	Section string
+	Notice  string
+	Error   string
+}
+
+func newAdminPage(r *http.Request, admin models.Users, section string) adminPage {
+	query := r.URL.Query()
+	return adminPage{
+		Admin:   admin,
+		CSRF:    middleware.CSRFTokenFromRequest(r),
+		Section: section,
+		Notice:  adminNotices[query.Get("notice")],
+		Error:   adminErrors[query.Get("error")],
+	}
+}
+
+// sessionAdmin is the server-rendered counterpart of requireAdmin.
+func sessionAdmin(w http.ResponseWriter, r *http.Request) (models.Users, bool) {
+	user, ok := sessionUser(w, r)
+	if !ok {
+		return user, false
+	}
+	if user.Role != models.RoleAdmin {
+		logging.FromContext(r.Context()).Warn("Forbidden admin action", zap.Uint("userID", user.ID))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return user, false
+	}
+	return user, true
+}
+
+// redirectAdmin sends the admin back to path after a form post, with a
+// notice or error key that the next page turns into a message.
+func redirectAdmin(w http.ResponseWriter, r *http.Request, path, param, key string) {
+	http.Redirect(w, r, path+"?"+param+"="+url.QueryEscape(key), http.StatusSeeOther)
+}
+
+// recordAudit writes an audit entry with tx, so it is committed or rolled
+// back together with the change it describes.
+func recordAudit(tx *gorm.DB, r *http.Request, actor models.Users, action, targetType string, targetID uint, reason string, changes map[string]models.Change) error {
+	return saveAudit(repository.NewGorm(tx).Audits, r, actor, action, targetType, targetID, reason, changes)
+}
+
+// saveAudit is recordAudit for handlers that write through repositories;
+// pass the Audits of the transaction's repositories.
+func saveAudit(audits repository.AuditRepository, r *http.Request, actor models.Users, action, targetType string, targetID uint, reason string, changes map[string]models.Change) error {
+	entry := models.AuditLog{
+		ActorID:    actor.ID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		IP:         clientIP(r),
+	}
+	if err := entry.SetChanges(changes); err != nil {
+		return err
+	}
+	if err := audits.Record(r.Context(), &entry); err != nil {
+		return err
+	}
+
+	logging.FromContext(r.Context()).Info("Admin action", zap.String("action", action), zap.Uint("adminID", actor.ID),
+		zap.String("targetType", targetType), zap.Uint("targetID", targetID))
+	return nil
+}
+
+type pager struct {
+	Page int
+	Prev string
+	Next string
+}
+
+func newPager(r *http.Request) pager {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	return pager{Page: page}
+}
+
+// scope limits query to the current page, fetching one extra row so finish
+// can tell whether there is a next page.
+func (p pager) scope(query *gorm.DB) *gorm.DB {
+	return query.Offset((p.Page - 1) * adminPageSize).Limit(adminPageSize + 1)
+}
+
+// finish sets the previous and next links and returns how many of the
+// fetched rows belong on this page.
+func (p *pager) finish(r *http.Request, fetched int) int {
+	link := func(page int) string {
+		query := r.URL.Query()
+		query.Del("notice")
+		query.Del("error")
+		query.Set("page", strconv.Itoa(page))
+		return r.URL.Path + "?" + query.Encode()
+	}
+	if p.Page > 1 {
+		p.Prev = link(p.Page - 1)
+	}
+	if fetched > adminPageSize {
+		p.Next = link(p.Page + 1)
+		return adminPageSize
+	}
+	return fetched
+}
+
+func likePattern(search string) string {
+	return "%" + strings.ToLower(strings.TrimSpace(search)) + "%"
+}
+
+func formInt(r *http.Request, name string) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(r.PostFormValue(name)))
+}
+
+func AdminHome(w http.ResponseWriter, r *http.Request) {
+	if _, ok := sessionAdmin(w, r); !ok {
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+type adminUsersPage struct {
+	adminPage
+	Search string
+	Users  []models.Users
+	Pager  pager
+}
+
+func AdminUsers(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	page := adminUsersPage{adminPage: newAdminPage(r, admin, "users"), Search: r.URL.Query().Get("q"), Pager: newPager(r)}
+
+	query := models.DB.Order("id")
+	if page.Search != "" {
+		pattern := likePattern(page.Search)
+		query = query.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", pattern, pattern)
+	}
+	if err := page.Pager.scope(query).Find(&page.Users).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to search users", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	page.Users = page.Users[:page.Pager.finish(r, len(page.Users))]
+
+	renderPage(w, http.StatusOK, "admin_users.html", page)
+}
+
+type adminUserPage struct {
+	adminPage
+	User    models.Users
+	Roles   []string
+	History []models.AuditLog
+}
+
+func AdminUser(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	page := adminUserPage{
+		adminPage: newAdminPage(r, admin, "users"),
+		Roles:     []string{models.RolePlayer, models.RoleManager, models.RoleAdmin},
+	}
+	if err := models.DB.Where("id = ?", mux.Vars(r)["id"]).First(&page.User).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	err := models.DB.Preload("Actor").
+		Where("target_type = ? AND target_id = ?", models.AuditTargetUser, page.User.ID).
+		Order("id DESC").
+		Limit(adminPageSize).
+		Find(&page.History).Error
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load audit history", zap.Error(err))
+	}
+
+	renderPage(w, http.StatusOK, "admin_user.html", page)
+}
+
+var adminEmailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+func AdminUpdateUser(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var user models.Users
+	if err := models.DB.Where("id = ?", mux.Vars(r)["id"]).First(&user).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	back := "/admin/users/" + strconv.Itoa(int(user.ID))
+
+	username := strings.TrimSpace(r.PostFormValue("username"))
+	email := strings.TrimSpace(r.PostFormValue("email"))
+	role := r.PostFormValue("role")
+	verified := r.PostFormValue("verified") != ""
+
+	// Service accounts keep their role; they are managed through the API.
+	if user.Role == models.RoleService {
+		role = models.RoleService
+	}
+	validRole := role == models.RolePlayer || role == models.RoleManager || role == models.RoleAdmin || role == models.RoleService
+	if username == "" || !adminEmailPattern.MatchString(email) || !validRole {
+		redirectAdmin(w, r, back, "error", "invalid-input")
+		return
+	}
+	if role == models.RoleService && user.Role != models.RoleService {
+		redirectAdmin(w, r, back, "error", "invalid-input")
+		return
+	}
+	if user.ID == admin.ID && role != user.Role {
+		redirectAdmin(w, r, back, "error", "own-role")
+		return
+	}
+
+	var existing int64
+	if models.DB.Model(&models.Users{}).Where("username = ? AND id <> ?", username, user.ID).Count(&existing); existing > 0 {
+		redirectAdmin(w, r, back, "error", "username-taken")
+		return
+	}
+	if models.DB.Model(&models.Users{}).Where("email = ? AND id <> ?", email, user.ID).Count(&existing); existing > 0 {
+		redirectAdmin(w, r, back, "error", "email-taken")
+		return
+	}
+
+	changes := map[string]models.Change{}
+	updates := map[string]interface{}{}
+	if username != user.Username {
+		changes["username"] = models.Change{From: user.Username, To: username}
+		updates["username"] = username
+	}
+	if email != user.Email {
+		changes["email"] = models.Change{From: user.Email, To: email}
+		updates["email"] = email
+	}
+	if role != user.Role {
+		changes["role"] = models.Change{From: user.Role, To: role}
+		updates["role"] = role
+	}
+	if verified != user.IsVerified() {
+		changes["email_verified"] = models.Change{From: user.IsVerified(), To: verified}
+		if verified {
+			updates["email_verified_at"] = time.Now()
+		} else {
+			updates["email_verified_at"] = nil
+		}
+	}
+
+	if len(updates) > 0 {
+		err := models.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Updates(updates).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, r, admin, "user.update", models.AuditTargetUser, user.ID, r.PostFormValue("reason"), changes)
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to update user", zap.Uint("userID", user.ID), zap.Error(err))
+			redirectAdmin(w, r, back, "error", "save-failed")
+			return
+		}
+	}
+
+	redirectAdmin(w, r, back, "notice", "user-saved")
+}
+
+func AdminAdjustPoints(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var user models.Users
+	if err := models.DB.Where("id = ?", mux.Vars(r)["id"]).First(&user).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	back := "/admin/users/" + strconv.Itoa(int(user.ID))
+
+	delta, err := formInt(r, "delta")
+	if err != nil || delta == 0 {
+		redirectAdmin(w, r, back, "error", "invalid-input")
+		return
+	}
+	reason := strings.TrimSpace(r.PostFormValue("reason"))
+	if reason == "" {
+		redirectAdmin(w, r, back, "error", "reason-required")
+		return
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		before := user.Point
+		if err := tx.Model(&user).UpdateColumn("point", gorm.Expr("point + ?", delta)).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("point").Where("id = ?", user.ID).First(&user).Error; err != nil {
+			return err
+		}
+		changes := map[string]models.Change{"point": {From: before, To: user.Point}}
+		return recordAudit(tx, r, admin, "user.points", models.AuditTargetUser, user.ID, reason, changes)
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to adjust points", zap.Uint("userID", user.ID), zap.Error(err))
+		redirectAdmin(w, r, back, "error", "save-failed")
+		return
+	}
+
+	redirectAdmin(w, r, back, "notice", "points-adjusted")
+}
+
+type adminAuditPage struct {
+	adminPage
+	TargetTypes []string
+	TargetType  string
+	TargetID    string
+	Entries     []models.AuditLog
+	Pager       pager
+}
+
+func AdminAuditLog(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	page := adminAuditPage{
+		adminPage:   newAdminPage(r, admin, "audit"),
+		TargetTypes: []string{models.AuditTargetUser, models.AuditTargetQuest, models.AuditTargetUom, models.AuditTargetProduct, models.AuditTargetSystem},
+		TargetType:  params.Get("target_type"),
+		TargetID:    params.Get("target_id"),
+		Pager:       newPager(r),
+	}
+
+	query := models.DB.Preload("Actor").Order("id DESC")
+	if page.TargetType != "" {
+		query = query.Where("target_type = ?", page.TargetType)
+	}
+	if id, err := strconv.ParseUint(page.TargetID, 10, 64); err == nil {
+		query = query.Where("target_id = ?", id)
+	}
+	if err := page.Pager.scope(query).Find(&page.Entries).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load audit log", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	page.Entries = page.Entries[:page.Pager.finish(r, len(page.Entries))]
+
+	renderPage(w, http.StatusOK, "admin_audit.html", page)
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"test/logging"
+	"test/metrics"
+	"test/models"
+	"test/repository"
+
+	"go.uber.org/zap"
+)
+
+const adminInventoryPath = "/admin/inventory"
+
+type adminInventoryPage struct {
+	adminPage
+	Uoms     []models.Uom
+	Products []models.Product
+}
+
+func (h *Handler) AdminInventory(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	page := adminInventoryPage{adminPage: newAdminPage(r, admin, "inventory")}
+	if page.Uoms, err = h.uoms.List(r.Context()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load uoms", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if page.Products, err = h.products.List(r.Context()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load products", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	renderPage(w, http.StatusOK, "admin_inventory.html", page)
+}
+
+// saveInventory runs change and its audit entry in one transaction and
+// redirects back to the inventory page. It reports whether the change was
+// committed.
+func (h *Handler) saveInventory(w http.ResponseWriter, r *http.Request, notice string, change func(repos repository.Repositories) error) bool {
+	if err := h.tx.Transaction(r.Context(), change); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to save inventory change", zap.Error(err))
+		redirectAdmin(w, r, adminInventoryPath, "error", "save-failed")
+		return false
+	}
+	redirectAdmin(w, r, adminInventoryPath, "notice", notice)
+	return true
+}
+
+func (h *Handler) AdminCreateUom(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	if name == "" {
+		redirectAdmin(w, r, adminInventoryPath, "error", "invalid-input")
+		return
+	}
+
+	h.saveInventory(w, r, "uom-created", func(repos repository.Repositories) error {
+		uom := models.Uom{Name: name, UserID: admin.ID}
+		if err := repos.Uoms.Create(r.Context(), &uom); err != nil {
+			return err
+		}
+		changes := map[string]models.Change{"name": {From: nil, To: uom.Name}}
+		return saveAudit(repos.Audits, r, admin, "uom.create", models.AuditTargetUom, uom.ID, "", changes)
+	})
+}
+
+func (h *Handler) AdminUpdateUom(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r)
+	var uom models.Uom
+	if err == nil {
+		uom, err = h.uoms.Get(r.Context(), id)
+	}
+	if err != nil {
+		http.Error(w, "Uom not found", http.StatusNotFound)
+		return
+	}
+
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	if name == "" {
+		redirectAdmin(w, r, adminInventoryPath, "error", "invalid-input")
+		return
+	}
+
+	h.saveInventory(w, r, "uom-saved", func(repos repository.Repositories) error {
+		changes := map[string]models.Change{"name": {From: uom.Name, To: name}}
+		uom.Name = name
+		if err := repos.Uoms.Update(r.Context(), &uom); err != nil {
+			return err
+		}
+		return saveAudit(repos.Audits, r, admin, "uom.update", models.AuditTargetUom, uom.ID, "", changes)
+	})
+}
+
+func (h *Handler) AdminDeleteUom(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r)
+	var uom models.Uom
+	if err == nil {
+		uom, err = h.uoms.Get(r.Context(), id)
+	}
+	if err != nil {
+		http.Error(w, "Uom not found", http.StatusNotFound)
+		return
+	}
+
+	if products, _ := h.products.CountByUom(r.Context(), uom.ID); products > 0 {
+		redirectAdmin(w, r, adminInventoryPath, "error", "uom-in-use")
+		return
+	}
+
+	h.saveInventory(w, r, "uom-deleted", func(repos repository.Repositories) error {
+		if err := repos.Uoms.Delete(r.Context(), uom.ID); err != nil {
+			return err
+		}
+		changes := map[string]models.Change{"name": {From: uom.Name, To: nil}}
+		return saveAudit(repos.Audits, r, admin, "uom.delete", models.AuditTargetUom, uom.ID, "", changes)
+	})
+}
+
+type productForm struct {
+	Name  string
+	Qty   int
+	UomID uint
+}
+
+func (h *Handler) parseProductForm(r *http.Request) (productForm, bool) {
+	qty, err := formInt(r, "qty")
+	if err != nil || qty < 0 {
+		return productForm{}, false
+	}
+	uomID, err := formInt(r, "uom_id")
+	if err != nil || uomID <= 0 {
+		return productForm{}, false
+	}
+	form := productForm{Name: strings.TrimSpace(r.PostFormValue("name")), Qty: qty, UomID: uint(uomID)}
+	if form.Name == "" {
+		return productForm{}, false
+	}
+
+	_, err = h.uoms.Get(r.Context(), form.UomID)
+	return form, err == nil
+}
+
+func (h *Handler) AdminCreateProduct(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	form, valid := h.parseProductForm(r)
+	if !valid {
+		redirectAdmin(w, r, adminInventoryPath, "error", "invalid-input")
+		return
+	}
+
+	saved := h.saveInventory(w, r, "product-created", func(repos repository.Repositories) error {
+		product := models.Product{Name: form.Name, Qty: form.Qty, UomID: form.UomID, UserID: admin.ID}
+		if err := repos.Products.Create(r.Context(), &product); err != nil {
+			return err
+		}
+		changes := map[string]models.Change{
+			"name":   {From: nil, To: product.Name},
+			"qty":    {From: nil, To: product.Qty},
+			"uom_id": {From: nil, To: product.UomID},
+		}
+		return saveAudit(repos.Audits, r, admin, "product.create", models.AuditTargetProduct, product.ID, "", changes)
+	})
+	if saved {
+		metrics.StockMoved(form.Qty)
+	}
+}
+
+func (h *Handler) AdminUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r)
+	var product models.Product
+	if err == nil {
+		product, err = h.products.Get(r.Context(), id)
+	}
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	form, valid := h.parseProductForm(r)
+	if !valid {
+		redirectAdmin(w, r, adminInventoryPath, "error", "invalid-input")
+		return
+	}
+
+	changes := map[string]models.Change{}
+	if form.Name != product.Name {
+		changes["name"] = models.Change{From: product.Name, To: form.Name}
+	}
+	if form.Qty != product.Qty {
+		changes["qty"] = models.Change{From: product.Qty, To: form.Qty}
+	}
+	if form.UomID != product.UomID {
+		changes["uom_id"] = models.Change{From: product.UomID, To: form.UomID}
+	}
+	if len(changes) == 0 {
+		redirectAdmin(w, r, adminInventoryPath, "notice", "product-saved")
+		return
+	}
+
+	saved := h.saveInventory(w, r, "product-saved", func(repos repository.Repositories) error {
+		updated := product
+		updated.Name, updated.Qty, updated.UomID = form.Name, form.Qty, form.UomID
+		if err := repos.Products.Update(r.Context(), &updated); err != nil {
+			return err
+		}
+		return saveAudit(repos.Audits, r, admin, "product.update", models.AuditTargetProduct, product.ID, "", changes)
+	})
+	if saved {
+		metrics.StockMoved(form.Qty - product.Qty)
+	}
+}
+
+func (h *Handler) AdminDeleteProduct(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r)
+	var product models.Product
+	if err == nil {
+		product, err = h.products.Get(r.Context(), id)
+	}
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	saved := h.saveInventory(w, r, "product-deleted", func(repos repository.Repositories) error {
+		if err := repos.Products.Delete(r.Context(), product.ID); err != nil {
+			return err
+		}
+		changes := map[string]models.Change{
+			"name": {From: product.Name, To: nil},
+			"qty":  {From: product.Qty, To: nil},
+		}
+		return saveAudit(repos.Audits, r, admin, "product.delete", models.AuditTargetProduct, product.ID, "", changes)
+	})
+	if saved {
+		metrics.StockMoved(-product.Qty)
+	}
+}
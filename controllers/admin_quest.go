@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"test/logging"
+	"test/models"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type adminQuestsPage struct {
+	adminPage
+	Search   string
+	Status   string
+	Statuses []models.QuestStatus
+	Quests   []models.Quest
+	Pager    pager
+}
+
+func AdminQuests(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	page := adminQuestsPage{
+		adminPage: newAdminPage(r, admin, "quests"),
+		Search:    params.Get("q"),
+		Status:    params.Get("status"),
+		Statuses:  []models.QuestStatus{models.QuestActive, models.QuestHidden, models.QuestArchived},
+		Pager:     newPager(r),
+	}
+
+	query := models.DB.Order("id DESC")
+	if page.Search != "" {
+		pattern := likePattern(page.Search)
+		query = query.Where("LOWER(title) LIKE ? OR LOWER(description) LIKE ?", pattern, pattern)
+	}
+	if models.QuestStatus(page.Status).Valid() {
+		query = query.Where("status = ?", page.Status)
+	}
+	if err := page.Pager.scope(query).Find(&page.Quests).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to search quests", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	page.Quests = page.Quests[:page.Pager.finish(r, len(page.Quests))]
+
+	renderPage(w, http.StatusOK, "admin_quests.html", page)
+}
+
+// AdminSetQuestStatus hides, archives or restores a quest. Pulling a quest
+// requires a reason, which is kept in the audit log.
+func AdminSetQuestStatus(w http.ResponseWriter, r *http.Request) {
+	admin, ok := sessionAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var quest models.Quest
+	if err := models.DB.Where("id = ?", mux.Vars(r)["id"]).First(&quest).Error; err != nil {
+		http.Error(w, "Quest not found", http.StatusNotFound)
+		return
+	}
+
+	status := models.QuestStatus(r.PostFormValue("status"))
+	if !status.Valid() {
+		redirectAdmin(w, r, "/admin/quests", "error", "invalid-input")
+		return
+	}
+	reason := strings.TrimSpace(r.PostFormValue("reason"))
+	if status != models.QuestActive && reason == "" {
+		redirectAdmin(w, r, "/admin/quests", "error", "reason-required")
+		return
+	}
+	if status == quest.Status {
+		redirectAdmin(w, r, "/admin/quests", "notice", "quest-updated")
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&quest).Update("status", status).Error; err != nil {
+			return err
+		}
+		changes := map[string]models.Change{"status": {From: quest.Status, To: status}}
+		return recordAudit(tx, r, admin, "quest.status", models.AuditTargetQuest, quest.ID, reason, changes)
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to update quest status", zap.Uint("questID", quest.ID), zap.Error(err))
+		redirectAdmin(w, r, "/admin/quests", "error", "save-failed")
+		return
+	}
+
+	redirectAdmin(w, r, "/admin/quests", "notice", "quest-updated")
+}
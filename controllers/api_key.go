@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"test/logging"
+	"test/models"
+	"test/utils"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const apiKeyPrefix = "qk_"
+
+type APIKeyInput struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreatedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+func decodeAPIKeyInput(w http.ResponseWriter, r *http.Request) (APIKeyInput, bool) {
+	var input APIKeyInput
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return input, false
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return input, false
+	}
+
+	validate = validator.New()
+	if err := validate.Struct(input); err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
+		return input, false
+	}
+
+	for _, scope := range input.Scopes {
+		if !models.ValidScope(scope) {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown scope %q", scope))
+			return input, false
+		}
+	}
+
+	if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Expiry must be in the future")
+		return input, false
+	}
+	return input, true
+}
+
+// issueAPIKey creates a key for owner and writes it, including the raw
+// secret, to the response. Only a hash of the key is stored, so the caller
+// sees the plaintext once and it cannot be recovered afterwards.
+func issueAPIKey(w http.ResponseWriter, owner models.Users, input APIKeyInput) (models.APIKey, bool) {
+	secret, err := randomToken(32)
+	if err != nil {
+		logging.Error("Failed to generate API key", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return models.APIKey{}, false
+	}
+	prefix, err := randomToken(6)
+	if err != nil {
+		logging.Error("Failed to generate API key", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return models.APIKey{}, false
+	}
+	raw := apiKeyPrefix + prefix + "_" + secret
+
+	key := models.APIKey{
+		UserID:    owner.ID,
+		Name:      input.Name,
+		Prefix:    apiKeyPrefix + prefix,
+		KeyHash:   models.HashToken(raw),
+		Scopes:    strings.Join(uniqueStrings(input.Scopes), ","),
+		ExpiresAt: input.ExpiresAt,
+	}
+
+	if err := models.DB.Create(&key).Error; err != nil {
+		logging.Error("Failed to create API key", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create API key")
+		return models.APIKey{}, false
+	}
+
+	logging.Info("API key created", zap.Uint("userID", owner.ID), zap.Uint("apiKeyID", key.ID), zap.String("scopes", key.Scopes))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreatedAPIKey{APIKey: key, Key: raw})
+	return key, true
+}
+
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	input, ok := decodeAPIKeyInput(w, r)
+	if !ok {
+		return
+	}
+
+	issueAPIKey(w, user, input)
+}
+
+func GetMyAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var keys []models.APIKey
+	if err := models.DB.Where("user_id = ?", user.ID).Order("id").Find(&keys).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load API keys", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	json.NewEncoder(w).Encode(keys)
+}
+
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	var key models.APIKey
+
+	query := models.DB.Where("id = ?", id)
+	if user.Role != models.RoleAdmin {
+		query = query.Where("user_id = ?", user.ID)
+	}
+	if err := query.First(&key).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("API key not found")
+		utils.RespondWithError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		err := models.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&key).Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+			if key.UserID == user.ID {
+				return nil
+			}
+			// An admin revoking someone else's key.
+			changes := map[string]models.Change{"api_key": {From: key.Prefix, To: nil}}
+			return recordAudit(tx, r, user, "api_key.revoke", models.AuditTargetUser, key.UserID, "", changes)
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to revoke API key", zap.Error(err))
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke API key")
+			return
+		}
+		logging.FromContext(r.Context()).Info("API key revoked", zap.Uint("apiKeyID", key.ID), zap.Uint("by", user.ID))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ServiceAccountInput struct {
+	Username string `json:"username" validate:"required"`
+	Email    string `json:"email"`
+}
+
+func CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	var input ServiceAccountInput
+
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	validate = validator.New()
+	if err := validate.Struct(input); err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
+		return
+	}
+
+	var existing models.Users
+	if err := models.DB.Where("username = ?", input.Username).First(&existing).Error; err == nil {
+		utils.RespondWithError(w, http.StatusConflict, "Username already exists")
+		return
+	}
+
+	now := time.Now()
+	account := &models.Users{
+		Username:        input.Username,
+		Email:           input.Email,
+		Role:            models.RoleService,
+		EmailVerifiedAt: &now,
+	}
+	if err := models.DB.Create(account).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to create service account", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create service account")
+		return
+	}
+
+	changes := map[string]models.Change{"username": {From: nil, To: account.Username}}
+	if err := recordAudit(models.DB, r, admin, "service_account.create", models.AuditTargetUser, account.ID, "", changes); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record audit entry", zap.Error(err))
+	}
+
+	logging.FromContext(r.Context()).Info("Service account created", zap.Uint("userID", account.ID), zap.Uint("adminID", admin.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(account)
+}
+
+func CreateServiceAccountKey(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	var account models.Users
+	if err := models.DB.Where("id = ? AND role = ?", id, models.RoleService).First(&account).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("Service account not found")
+		utils.RespondWithError(w, http.StatusNotFound, "Service account not found")
+		return
+	}
+
+	input, ok := decodeAPIKeyInput(w, r)
+	if !ok {
+		return
+	}
+
+	key, ok := issueAPIKey(w, account, input)
+	if !ok {
+		return
+	}
+
+	changes := map[string]models.Change{"api_key": {From: nil, To: key.Prefix}, "scopes": {From: nil, To: key.Scopes}}
+	if err := recordAudit(models.DB, r, admin, "service_account.key_create", models.AuditTargetUser, account.ID, "", changes); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record audit entry", zap.Error(err))
+	}
+}
+
+func uniqueStrings(values []string) []string {
+	unique := make([]string, 0, len(values))
+	seen := map[string]bool{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,41 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strconv"
+	"test/models"
+	"testing"
+)
+
+func TestRevokeAPIKeyAuditsAdminRevocations(t *testing.T) {
+	s := newTestServer(t)
+	_, admin := s.signUp(t, "root", models.RoleAdmin)
+	alice, player := s.signUp(t, "alice", models.RolePlayer)
+
+	createKey := func(name string) uint {
+		var created struct {
+			ID uint `json:"id"`
+		}
+		body := map[string]interface{}{"name": name, "scopes": []string{models.ScopeQuestsRead}}
+		if status := s.do(t, "POST", "/api/me/api-keys", player, body, &created); status != http.StatusCreated {
+			t.Fatalf("create API key: status %d", status)
+		}
+		return created.ID
+	}
+	own, other := createKey("laptop"), createKey("ci")
+
+	if status := s.do(t, "DELETE", "/api/me/api-keys/"+strconv.Itoa(int(own)), player, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("revoke own key: status %d", status)
+	}
+	if status := s.do(t, "DELETE", "/api/me/api-keys/"+strconv.Itoa(int(other)), admin, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("admin revoke: status %d", status)
+	}
+
+	var entries []models.AuditLog
+	if err := models.DB.Where("action = ?", "api_key.revoke").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].TargetID != alice.ID || entries[0].TargetType != models.AuditTargetUser {
+		t.Errorf("audit entries = %+v, want only the admin revocation of alice's key", entries)
+	}
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"test/logging"
+	"test/middleware"
+	"test/models"
+	"test/utils"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type AssignQuestInput struct {
+	AssigneeID        uint       `json:"assignee_id" validate:"required"`
+	DueAt             *time.Time `json:"due_at"`
+	ExpireWhenOverdue bool       `json:"expire_when_overdue"`
+}
+
+func AssignQuest(w http.ResponseWriter, r *http.Request) {
+	var input AssignQuestInput
+	var manager models.Users
+	var assignee models.Users
+	var quest models.Quest
+
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Unauthorized", zap.Error(err))
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := models.DB.Where("id = ?", userID).First(&manager).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("User not found")
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if !manager.CanManageQuests() {
+		logging.FromContext(r.Context()).Warn("Forbidden quest assignment", zap.Uint("userID", userID))
+		utils.RespondWithError(w, http.StatusForbidden, "Only managers can assign quests")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if err := models.DB.Where("id = ? AND status = ?", id, models.QuestActive).First(&quest).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("Quest not found")
+		utils.RespondWithError(w, http.StatusNotFound, "Quest not found")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	err = json.Unmarshal(body, &input)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	validate = validator.New()
+	err = validate.Struct(input)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
+		return
+	}
+
+	if input.DueAt != nil && input.DueAt.Before(time.Now()) {
+		logging.FromContext(r.Context()).Warn("Assignment due date in the past")
+		utils.RespondWithError(w, http.StatusBadRequest, "Due date must be in the future")
+		return
+	}
+
+	if err := models.DB.Where("id = ?", input.AssigneeID).First(&assignee).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("Assignee not found")
+		utils.RespondWithError(w, http.StatusNotFound, "Assignee not found")
+		return
+	}
+
+	var open int64
+	models.DB.Model(&models.QuestAssignment{}).
+		Where("quest_id = ? AND assignee_id = ? AND status IN ?", quest.ID, assignee.ID,
+			[]string{models.AssignmentAssigned, models.AssignmentInProgress, models.AssignmentOverdue}).
+		Count(&open)
+	if open > 0 {
+		logging.FromContext(r.Context()).Warn("Quest already assigned", zap.Uint("questID", quest.ID), zap.Uint("assigneeID", assignee.ID))
+		utils.RespondWithError(w, http.StatusConflict, "Quest already assigned to this user")
+		return
+	}
+
+	assignment := &models.QuestAssignment{
+		QuestID:           quest.ID,
+		AssigneeID:        assignee.ID,
+		AssignedByID:      manager.ID,
+		DueAt:             input.DueAt,
+		ExpireWhenOverdue: input.ExpireWhenOverdue,
+		Status:            models.AssignmentAssigned,
+	}
+
+	if err := models.DB.Create(assignment).Error; err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to assign quest")
+		return
+	}
+	assignment.Quest = quest
+
+	logging.FromContext(r.Context()).Info("Quest assigned", zap.Uint("questID", quest.ID), zap.Uint("assigneeID", assignee.ID), zap.Uint("assignedBy", manager.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(assignment)
+}
+
+func GetMyQuests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Unauthorized")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if _, err := models.MarkOverdueAssignments(models.DB, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to mark overdue assignments", zap.Error(err))
+	}
+
+	query := models.DB.Preload("Quest").Where("assignee_id = ?", userID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		if !models.ValidAssignmentStatus(status) {
+			logging.FromContext(r.Context()).Warn("Invalid assignment status filter")
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid status")
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var assignments []models.QuestAssignment
+	if err := query.Order("due_at IS NULL, due_at, id").Find(&assignments).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load assignments", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	json.NewEncoder(w).Encode(assignments)
+}
+
+func StartMyQuest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Unauthorized")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var user models.Users
+	if err := models.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("User not found")
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if !requireVerified(w, user) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	var assignment models.QuestAssignment
+
+	if err := models.DB.Preload("Quest").Where("id = ? AND assignee_id = ?", id, userID).First(&assignment).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("Assignment not found")
+		utils.RespondWithError(w, http.StatusNotFound, "Assignment not found")
+		return
+	}
+
+	if !assignment.Open() {
+		logging.FromContext(r.Context()).Warn("Assignment is closed", zap.Uint("assignmentID", assignment.ID))
+		utils.RespondWithError(w, http.StatusConflict, "Assignment is "+assignment.Status)
+		return
+	}
+
+	now := time.Now()
+	assignment.StartedAt = &now
+	if assignment.Status == models.AssignmentAssigned {
+		assignment.Status = models.AssignmentInProgress
+	}
+
+	if err := models.DB.Save(&assignment).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to start assignment", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to start assignment")
+		return
+	}
+
+	json.NewEncoder(w).Encode(assignment)
+}
@@ -1,33 +1,37 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
-	"html/template"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"regexp"
 	"strconv"
 	"test/logging"
+	"test/metrics"
 	"test/middleware"
 	"test/models"
+	"test/repository"
 	"test/utils"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 )
 
 func LoginHTML(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles("templates/login.html")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if err := tmpl.Execute(w, nil); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if _, ok := loginCSRFCookie(r); !ok {
+		token, err := randomToken(24)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		setLoginCSRFCookie(w, r, token)
+		r.AddCookie(&http.Cookie{Name: loginCSRFCookieName, Value: token})
 	}
+
+	renderLogin(w, r, http.StatusOK, loginPage{Next: safeNext(r.URL.Query().Get("next"))})
 }
 
 type UserInput struct {
@@ -36,41 +40,40 @@ type UserInput struct {
 	Password string `json:"password" validate:"required"`
 }
 
-func Register(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var input UserInput
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		logging.Error("Failed to read request body", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Failed to read request body", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read request body")
 		return
 	}
 
 	err = json.Unmarshal(body, &input)
 	if err != nil {
-		logging.Error("Invalid request body", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	emailRegex := regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
 	if !emailRegex.MatchString(input.Email) {
-		logging.Warn("Email must be in valid format")
+		logging.FromContext(r.Context()).Warn("Email must be in valid format")
 		utils.RespondWithError(w, http.StatusBadRequest, "Email must be in valid format")
 		return
 	}
 
-	existingUser := models.Users{}
-	err = models.DB.Where("email = ?", input.Email).First(&existingUser).Error
+	_, err = h.users.FindByEmail(r.Context(), input.Email)
 	if err == nil {
-		logging.Warn("Email already exists")
+		logging.FromContext(r.Context()).Warn("Email already exists")
 		utils.RespondWithError(w, http.StatusConflict, "Email already exists")
 		return
 	}
 
-	err = models.DB.Where("username = ?", input.Username).First(&existingUser).Error
+	_, err = h.users.FindByUsername(r.Context(), input.Username)
 	if err == nil {
-		logging.Warn("Username already exists")
+		logging.FromContext(r.Context()).Warn("Username already exists")
 		utils.RespondWithError(w, http.StatusConflict, "Username already exists")
 		return
 	}
@@ -78,16 +81,25 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	newUser := &models.Users{
 		Username: input.Username,
 		Email:    input.Email,
-		Password: input.Password,
 	}
-	err = models.DB.Create(newUser).Error
+	if err := newUser.SetPassword(input.Password); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to hash password", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+	err = h.users.Create(r.Context(), newUser)
 	if err != nil {
-		logging.Error("Failed to create user", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Failed to create user", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
-	logging.Info("User created", zap.String("username", newUser.Username), zap.String("email", newUser.Email))
+	if err := h.sendVerificationEmail(r.Context(), newUser); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to send verification email", zap.Uint("userID", newUser.ID), zap.Error(err))
+	}
+
+	metrics.UserRegistered("password")
+	logging.FromContext(r.Context()).Info("User created", zap.String("username", newUser.Username), zap.String("email", newUser.Email))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(newUser)
 }
@@ -97,77 +109,144 @@ type LoginInput struct {
 	Password        string `json:"password" validate:"required"`
 }
 
-func Login(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var input LoginInput
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		logging.Error("Failed to read request body", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Failed to read request body", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read request body")
 		return
 	}
 
 	err = json.Unmarshal(body, &input)
 	if err != nil {
-		logging.Error("Invalid request body", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	existingUser := models.Users{}
-
-	identifier := regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
-	var userQuery *gorm.DB
-	if !identifier.MatchString(input.LoginIdentifier) {
-		userQuery = models.DB.Where("username = ?", input.LoginIdentifier)
-	} else {
-		userQuery = models.DB.Where("email = ?", input.LoginIdentifier)
-	}
-
-	if err := userQuery.First(&existingUser).Error; err != nil {
-		logging.Warn("User not found")
-		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+	user, failure := h.authenticatePassword(r, input.LoginIdentifier, input.Password)
+	if failure != nil {
+		failure.respond(w)
 		return
 	}
 
-	if existingUser.Password != input.Password {
-		logging.Warn("Invalid Credentials")
-		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+	if user.TOTPEnabled {
+		logging.FromContext(r.Context()).Info("MFA challenge issued", zap.String("username", user.Username))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    h.issueMFAChallenge(user),
+		})
 		return
 	}
 
-	token, err := generateJWTToken(existingUser.ID)
+	token, err := h.startSession(r.Context(), &user)
 	if err != nil {
-		logging.Warn("Failed Generate token")
+		logging.FromContext(r.Context()).Warn("Failed Generate token")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
-	existingUser.Token = token
-
-	models.DB.Save(&existingUser)
-
-	logging.Info("User Login", zap.String("username", existingUser.Username), zap.String("token", existingUser.Token))
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"token": token})
 }
 
-func GetInfo(w http.ResponseWriter, r *http.Request) {
+type loginFailure struct {
+	status     int
+	message    string
+	retryAfter time.Duration
+}
+
+func (f *loginFailure) respond(w http.ResponseWriter) {
+	if f.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(f.retryAfter.Seconds())+1))
+	}
+	utils.RespondWithError(w, f.status, f.message)
+}
+
+// authenticatePassword is the password step shared by the JSON and form
+// logins, including throttling.
+func (h *Handler) authenticatePassword(r *http.Request, loginIdentifier, password string) (models.Users, *loginFailure) {
+	var existingUser models.Users
+	var err error
+
+	identifier := regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	if !identifier.MatchString(loginIdentifier) {
+		existingUser, err = h.users.FindByUsername(r.Context(), loginIdentifier)
+	} else {
+		existingUser, err = h.users.FindByEmail(r.Context(), loginIdentifier)
+	}
+
+	var account string
+	switch {
+	case err == nil:
+		account = loginAccountKey(&existingUser, loginIdentifier)
+	case errors.Is(err, repository.ErrNotFound):
+		account = loginAccountKey(nil, loginIdentifier)
+	default:
+		logging.FromContext(r.Context()).Error("Failed to load user", zap.Error(err))
+		return existingUser, &loginFailure{status: http.StatusInternalServerError, message: "Internal Server Error"}
+	}
+
+	if failure := reserveLoginAttempt(r, account); failure != nil {
+		return existingUser, failure
+	}
+
+	// Unknown users, accounts without a local password and wrong passwords
+	// get the same response, after the same bcrypt work, so the endpoint
+	// does not reveal which accounts exist.
+	if !existingUser.CheckPassword(password) || existingUser.ID == 0 ||
+		existingUser.Role == models.RoleService {
+		logging.FromContext(r.Context()).Warn("Invalid Credentials")
+		metrics.LoginFailed()
+		return existingUser, &loginFailure{status: http.StatusUnauthorized, message: "Invalid credentials"}
+	}
+
+	recordLoginSuccess(r, account)
+	if existingUser.PasswordNeedsRehash() && existingUser.SetPassword(password) == nil {
+		if err := h.users.SetPassword(r.Context(), existingUser.ID, existingUser.Password); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to rehash password", zap.Uint("userID", existingUser.ID), zap.Error(err))
+		}
+	}
+	return existingUser, nil
+}
+
+// startSession issues a JWT for the user and records it as their current
+// token.
+func (h *Handler) startSession(ctx context.Context, user *models.Users) (string, error) {
+	token, err := h.generateJWTToken(*user)
+	if err != nil {
+		return "", err
+	}
+
+	user.Token = token
+	if err := h.users.SetToken(ctx, user.ID, token); err != nil {
+		return "", err
+	}
+
+	metrics.LoginSucceeded()
+	logging.FromContext(ctx).Info("User Login", zap.String("username", user.Username))
+	return token, nil
+}
+
+func (h *Handler) GetInfo(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	userID, err := middleware.GetUserIdFromToken(r)
 	if err != nil {
-		logging.Warn("Unauthorized")
+		logging.FromContext(r.Context()).Warn("Unauthorized")
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	var user models.Users
-	if err := models.DB.Preload("Quests").Preload("CompletedQuests").Where("id = ?", userID).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			logging.Warn("User not found")
+	user, err := h.users.GetProfile(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			logging.FromContext(r.Context()).Warn("User not found")
 			utils.RespondWithError(w, http.StatusNotFound, "User not found")
 			return
 		}
@@ -178,16 +257,29 @@ func GetInfo(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
-func generateJWTToken(userID uint) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(6) * time.Hour)
+func (h *Handler) generateJWTToken(user models.Users) (string, error) {
+	expirationTime := time.Now().Add(h.sessionTTL)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userID": userID,
+	claims := jwt.MapClaims{
+		"userID": user.ID,
+		"ver":    user.SessionVersion,
 		"exp":    expirationTime.Unix(),
-	})
+	}
 
-	logging.Info("User Generate Token", zap.String("userID", strconv.Itoa(int(userID))))
+	logging.Info("User Generate Token", zap.String("userID", strconv.Itoa(int(user.ID))))
 
-	secretKey := []byte("your-secret-key")
-	return token.SignedString(secretKey)
+	return h.signer.SignJWT(claims)
+}
+
+// generateImpersonationToken issues a token that acts as user on behalf of
+// admin. It is tied to both accounts' session versions, so revoking either
+// side's sessions ends the impersonation.
+func (h *Handler) generateImpersonationToken(user, admin models.Users, expiresAt time.Time) (string, error) {
+	return h.signer.SignJWT(jwt.MapClaims{
+		"userID":  user.ID,
+		"ver":     user.SessionVersion,
+		"act":     admin.ID,
+		"act_ver": admin.SessionVersion,
+		"exp":     expiresAt.Unix(),
+	})
 }
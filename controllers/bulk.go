@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"test/bulk"
+	"test/logging"
+	"test/models"
+	"test/utils"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const maxImportBytes = 64 << 20
+
+// bulkScopes lists the API key scopes needed to export and import each kind.
+var bulkScopes = map[string]struct{ read, write string }{
+	"quests":   {models.ScopeQuestsRead, models.ScopeQuestsWrite},
+	"uoms":     {models.ScopeInventoryRead, models.ScopeInventoryWrite},
+	"products": {models.ScopeInventoryRead, models.ScopeInventoryWrite},
+}
+
+func requestFormat(r *http.Request) (bulk.Format, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return bulk.ParseFormat(format)
+	}
+	return bulk.ParseFormat(r.Header.Get("Content-Type"))
+}
+
+// requireManager loads the caller and rejects anyone who may not manage
+// quests and inventory in bulk.
+func requireManager(w http.ResponseWriter, r *http.Request) (models.Users, bool) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return user, false
+	}
+	if !user.CanManageQuests() {
+		logging.FromContext(r.Context()).Warn("Forbidden bulk action", zap.Uint("userID", user.ID))
+		utils.RespondWithError(w, http.StatusForbidden, "Only managers can import or export records")
+		return user, false
+	}
+	return user, true
+}
+
+func ImportRecords(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireManager(w, r)
+	if !ok {
+		return
+	}
+
+	format, err := requestFormat(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid import format", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mode, err := bulk.ParseMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid import mode", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	kind := mux.Vars(r)["kind"]
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	report, err := bulk.Import(models.DB, kind, body, bulk.Options{
+		Format: format,
+		Mode:   mode,
+		DryRun: dryRun,
+		UserID: user.ID,
+	})
+	if errors.Is(err, bulk.ErrUnknownKind) {
+		utils.RespondWithError(w, http.StatusNotFound, "Unknown import kind")
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		logging.FromContext(r.Context()).Warn("Import too large", zap.String("kind", kind))
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Import is larger than 64 MB")
+		return
+	}
+	var inputErr *bulk.InputError
+	if errors.As(err, &inputErr) {
+		logging.FromContext(r.Context()).Warn("Unreadable import", zap.String("kind", kind), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Import failed at "+inputErr.Error())
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Import failed", zap.String("kind", kind), zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Import failed")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Import finished",
+		zap.String("kind", kind),
+		zap.Bool("dryRun", dryRun),
+		zap.Int("total", report.Total),
+		zap.Int("failed", report.Failed),
+		zap.Int("imported", report.Imported))
+
+	status := http.StatusOK
+	if report.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+func ExportRecords(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireManager(w, r); !ok {
+		return
+	}
+
+	format, err := bulk.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid export format", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	kind := mux.Vars(r)["kind"]
+	known := false
+	for _, name := range bulk.Kinds() {
+		known = known || name == kind
+	}
+	if !known {
+		utils.RespondWithError(w, http.StatusNotFound, "Unknown export kind")
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+kind+"."+string(format)+"\"")
+
+	if err := bulk.Export(models.DB, kind, format, w); err != nil {
+		// Headers are already sent, so the best we can do is log and stop.
+		logging.FromContext(r.Context()).Error("Export failed", zap.String("kind", kind), zap.Error(err))
+	}
+}
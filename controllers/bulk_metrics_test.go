@@ -0,0 +1,82 @@
+package controllers_test
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"test/models"
+	"testing"
+)
+
+// stockIn reads the units counted into stock from /metrics.
+func (s *testServer) stockIn(t *testing.T) float64 {
+	t.Helper()
+
+	resp, err := s.Client().Get(s.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	const series = `inventory_stock_moved_quantity_total{direction="in"} `
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), series); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return parsed
+		}
+	}
+	return 0
+}
+
+func TestProductImportCountsCommittedStock(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp(t, "alice", models.RoleManager)
+	uom := models.Uom{Name: "kg"}
+	if err := models.DB.Create(&uom).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	importCSV := func(query string, rows ...string) int {
+		body := "name,qty,uom_id\n" + strings.Join(rows, "\n") + "\n"
+		req, err := http.NewRequest("POST", s.URL+"/api/import/products?format=csv"+query, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", token)
+		resp, err := s.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	good := func(qty int) string { return "flour," + strconv.Itoa(qty) + "," + strconv.Itoa(int(uom.ID)) }
+	const bad = "sugar,1,9999"
+
+	tests := []struct {
+		name   string
+		query  string
+		rows   []string
+		status int
+		moved  float64
+	}{
+		{"dry run", "&dry_run=true", []string{good(5)}, http.StatusOK, 0},
+		{"atomic with a failing row", "", []string{good(5), bad}, http.StatusUnprocessableEntity, 0},
+		{"atomic", "", []string{good(5), good(7)}, http.StatusOK, 12},
+		{"best effort", "&mode=best-effort", []string{good(3), bad}, http.StatusUnprocessableEntity, 3},
+	}
+	for _, tt := range tests {
+		before := s.stockIn(t)
+		if status := importCSV(tt.query, tt.rows...); status != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, status, tt.status)
+		}
+		if moved := s.stockIn(t) - before; moved != tt.moved {
+			t.Errorf("%s: counted %v units in, want %v", tt.name, moved, tt.moved)
+		}
+	}
+}
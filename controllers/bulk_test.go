@@ -0,0 +1,82 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"test/models"
+	"testing"
+)
+
+// bulkRequest sends body to path with the given credential header and
+// returns the status and error message, if any.
+func (s *testServer) bulkRequest(t *testing.T, method, path, header, credential, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(header, credential)
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var failure struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&failure)
+	return resp.StatusCode, failure.Error
+}
+
+func TestBulkRoutesRequireManager(t *testing.T) {
+	s := newTestServer(t)
+	_, player := s.signUp(t, "alice", models.RolePlayer)
+	_, manager := s.signUp(t, "bob", models.RoleManager)
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	body := map[string]interface{}{"name": "sync", "scopes": []string{models.ScopeInventoryRead}}
+	if status := s.do(t, "POST", "/api/me/api-keys", manager, body, &created); status != http.StatusCreated {
+		t.Fatalf("create API key: status %d", status)
+	}
+
+	const uoms = "name\nkg\n"
+	tests := []struct {
+		name         string
+		method, path string
+		header       string
+		credential   string
+		status       int
+	}{
+		{"player import", "POST", "/api/import/uoms", "Authorization", player, http.StatusForbidden},
+		{"player export", "GET", "/api/export/uoms", "Authorization", player, http.StatusForbidden},
+		{"manager import", "POST", "/api/import/uoms", "Authorization", manager, http.StatusOK},
+		{"manager export", "GET", "/api/export/uoms", "Authorization", manager, http.StatusOK},
+		{"key export in scope", "GET", "/api/export/products", "X-API-Key", created.Key, http.StatusOK},
+		{"key export out of scope", "GET", "/api/export/quests", "X-API-Key", created.Key, http.StatusForbidden},
+		{"key import out of scope", "POST", "/api/import/uoms", "X-API-Key", created.Key, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if status, _ := s.bulkRequest(t, tt.method, tt.path, tt.header, tt.credential, uoms); status != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+}
+
+func TestImportReportsUnreadableRow(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp(t, "alice", models.RoleManager)
+
+	body := "name\nkg\nbad\"quote\n"
+	status, message := s.bulkRequest(t, "POST", "/api/import/uoms", "Authorization", token, body)
+	if status != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", status)
+	}
+	if !strings.HasPrefix(message, "Import failed at row 2: column 4:") {
+		t.Errorf("message = %q, want the row and column", message)
+	}
+}
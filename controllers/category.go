@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"test/logging"
+	"test/models"
+	"test/utils"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+func GetAllCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var categories []models.Category
+	if err := models.DB.Order("name").Find(&categories).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load categories", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	json.NewEncoder(w).Encode(buildCategoryTree(categories, nil))
+}
+
+func buildCategoryTree(categories []models.Category, parentID *uint) []models.Category {
+	tree := []models.Category{}
+	for _, category := range categories {
+		if (parentID == nil && category.ParentID == nil) ||
+			(parentID != nil && category.ParentID != nil && *parentID == *category.ParentID) {
+			id := category.ID
+			category.Children = buildCategoryTree(categories, &id)
+			tree = append(tree, category)
+		}
+	}
+	return tree
+}
+
+type CategoryInput struct {
+	Name     string `json:"name" validate:"required"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+func CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var input CategoryInput
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	err = json.Unmarshal(body, &input)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	validate = validator.New()
+	err = validate.Struct(input)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
+		return
+	}
+
+	if input.ParentID != nil {
+		var parent models.Category
+		if err := models.DB.Where("id = ?", *input.ParentID).First(&parent).Error; err != nil {
+			logging.FromContext(r.Context()).Warn("Parent category not found")
+			utils.RespondWithError(w, http.StatusNotFound, "Parent category not found")
+			return
+		}
+	}
+
+	category := &models.Category{
+		Name:     input.Name,
+		ParentID: input.ParentID,
+	}
+
+	if err := models.DB.Create(category).Error; err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create category")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"test/logging"
+	"test/metrics"
+	"test/middleware"
+	"test/models"
+	"test/repository"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	dashboardListSize = 20
+	leaderboardSize   = 10
+)
+
+type completedQuestRow struct {
+	QuestID     uint
+	Title       string
+	Reward      int
+	CompletedAt time.Time
+}
+
+type leaderboardEntry struct {
+	ID       uint
+	Username string
+	Point    int
+}
+
+// dashboardPage is the data rendered into templates/dashboard.html.
+type dashboardPage struct {
+	User        models.Users
+	CSRF        string
+	Notice      string
+	Error       string
+	Rank        int64
+	Completed   []completedQuestRow
+	Available   []models.Quest
+	Leaderboard []leaderboardEntry
+}
+
+func Dashboard(w http.ResponseWriter, r *http.Request) {
+	user, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	page := dashboardPage{}
+	if id, err := strconv.Atoi(r.URL.Query().Get("completed")); err == nil {
+		var quest models.Quest
+		if models.DB.Select("id", "title", "reward").Where("id = ?", id).First(&quest).Error == nil {
+			page.Notice = "Quest \"" + quest.Title + "\" completed: +" + strconv.Itoa(quest.Reward) + " points"
+		}
+	}
+
+	renderDashboard(w, r, http.StatusOK, user, page)
+}
+
+// CompleteQuestForm completes a quest from the dashboard and redirects back
+// to it, so a refresh does not resubmit the form.
+func (h *Handler) CompleteQuestForm(w http.ResponseWriter, r *http.Request) {
+	user, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	fail := func(status int, message string) {
+		renderDashboard(w, r, status, user, dashboardPage{Error: message})
+	}
+
+	id, err := pathID(r)
+	var quest models.Quest
+	if err == nil {
+		quest, err = h.quests.GetActive(r.Context(), id)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Quest not found")
+		fail(http.StatusNotFound, "Quest not found")
+		return
+	}
+
+	if !user.IsVerified() {
+		logging.FromContext(r.Context()).Warn("Unverified user blocked", zap.Uint("userID", user.ID))
+		fail(http.StatusForbidden, "Verify your email address before completing quests")
+		return
+	}
+
+	// The browser cannot prove a location without a separate check-in, so
+	// geofenced quests are completed from the app instead.
+	if quest.Geofence.Enabled() {
+		fail(http.StatusUnprocessableEntity, "This quest requires an on-site check-in")
+		return
+	}
+
+	if completed, _ := h.users.HasCompleted(r.Context(), user.ID, quest.ID); completed {
+		fail(http.StatusConflict, "You have already completed this quest")
+		return
+	}
+
+	if err := h.users.AwardQuest(r.Context(), &user, quest, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrAlreadyCompleted) {
+			fail(http.StatusConflict, "You have already completed this quest")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to update user", zap.Error(err))
+		fail(http.StatusInternalServerError, "Failed to complete quest")
+		return
+	}
+	metrics.QuestCompleted(quest.Reward)
+
+	logging.FromContext(r.Context()).Info("Quest completed from dashboard", zap.Uint("userID", user.ID), zap.Uint("questID", quest.ID))
+	http.Redirect(w, r, "/dashboard?completed="+strconv.Itoa(int(quest.ID)), http.StatusSeeOther)
+}
+
+// sessionUser loads the user behind the session, sending them back to the
+// login page if the account no longer exists.
+func sessionUser(w http.ResponseWriter, r *http.Request) (models.Users, bool) {
+	var user models.Users
+
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err == nil {
+		err = models.DB.Where("id = ?", userID).First(&user).Error
+	}
+	if err != nil {
+		middleware.ClearSessionCookie(w)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return user, false
+	}
+	return user, true
+}
+
+func renderDashboard(w http.ResponseWriter, r *http.Request, status int, user models.Users, page dashboardPage) {
+	page.User = user
+	page.CSRF = middleware.CSRFTokenFromRequest(r)
+
+	err := models.DB.Table("completed_quests").
+		Select("quests.id AS quest_id, quests.title, quests.reward, completed_quests.completed_at").
+		Joins("JOIN quests ON quests.id = completed_quests.quest_id").
+		Where("completed_quests.user_id = ?", user.ID).
+		Order("completed_quests.completed_at DESC").
+		Limit(dashboardListSize).
+		Scan(&page.Completed).Error
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load completed quests", zap.Error(err))
+	}
+
+	completedIDs := models.DB.Model(&models.CompletedQuest{}).Select("quest_id").Where("user_id = ?", user.ID)
+	err = models.DB.Preload("Tags").
+		Where("status = ? AND id NOT IN (?)", models.QuestActive, completedIDs).
+		Order("id DESC").
+		Limit(dashboardListSize).
+		Find(&page.Available).Error
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load available quests", zap.Error(err))
+	}
+
+	players := models.DB.Model(&models.Users{}).Where("role <> ?", models.RoleService).Session(&gorm.Session{})
+	err = players.
+		Select("id", "username", "point").
+		Order("point DESC, id").
+		Limit(leaderboardSize).
+		Scan(&page.Leaderboard).Error
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load leaderboard", zap.Error(err))
+	}
+
+	players.Where("point > ?", user.Point).Count(&page.Rank)
+	page.Rank++
+
+	renderPage(w, status, "dashboard.html", page)
+}
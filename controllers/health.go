@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"test/buildinfo"
+	"test/health"
+	"test/logging"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// readinessTimeout bounds each readiness check so a hung dependency fails the
+// probe instead of stalling it.
+const readinessTimeout = 2 * time.Second
+
+// Healthz reports that the process is up and serving requests. It checks no
+// dependencies, so a slow database does not get the process restarted.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Readyz runs the registered checks and answers 503 if any of them fails, so
+// the instance is taken out of rotation until it recovers. Callers only see
+// which checks failed; the errors are logged.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	results, ready := health.Run(r.Context(), readinessTimeout)
+
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+		for _, result := range results {
+			if !result.OK {
+				logging.FromContext(r.Context()).Warn("Readiness check failed", zap.String("check", result.Name),
+					zap.String("error", result.Error), zap.Duration("duration", result.Duration))
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "checks": results})
+}
+
+func Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildinfo.Read())
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"test/logging"
+	"test/middleware"
+	"test/models"
+	"test/utils"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const impersonationTTL = 15 * time.Minute
+
+type ImpersonateInput struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+type ImpersonationToken struct {
+	Token          string    `json:"token"`
+	UserID         uint      `json:"user_id"`
+	ImpersonatorID uint      `json:"impersonator_id"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// Impersonate issues a short-lived token that lets an admin see the API as
+// another user. Sensitive endpoints reject it and every request made with it
+// is logged.
+func (h *Handler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	var input ImpersonateInput
+
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	if principal, _ := middleware.PrincipalFromContext(r.Context()); principal.Impersonating() {
+		utils.RespondWithError(w, http.StatusForbidden, "Not allowed while impersonating")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	validate = validator.New()
+	if err := validate.Struct(input); err != nil {
+		logging.FromContext(r.Context()).Error("Validation Error", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
+		return
+	}
+
+	var user models.Users
+	if err := models.DB.Where("id = ?", mux.Vars(r)["id"]).First(&user).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("User not found")
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// Impersonating another admin would hand out their privileges.
+	if user.ID == admin.ID || user.Role == models.RoleAdmin || user.Role == models.RoleService {
+		logging.FromContext(r.Context()).Warn("Impersonation refused", zap.Uint("userID", user.ID), zap.Uint("adminID", admin.ID))
+		utils.RespondWithError(w, http.StatusForbidden, "This account cannot be impersonated")
+		return
+	}
+
+	expiresAt := time.Now().Add(impersonationTTL)
+	token, err := h.generateImpersonationToken(user, admin, expiresAt)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to generate impersonation token", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	if err := recordAudit(models.DB, r, admin, "user.impersonate", models.AuditTargetUser, user.ID, input.Reason, nil); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record audit entry", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Impersonation started", zap.Uint("userID", user.ID), zap.Uint("adminID", admin.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ImpersonationToken{
+		Token:          token,
+		UserID:         user.ID,
+		ImpersonatorID: admin.ID,
+		ExpiresAt:      expiresAt,
+	})
+}
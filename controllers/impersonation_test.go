@@ -0,0 +1,73 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"test/middleware"
+	"test/models"
+	"test/utils"
+	"testing"
+)
+
+// postAsSession posts to a server-rendered route with token as the session
+// cookie, without following the redirect.
+func (s *testServer) postAsSession(t *testing.T, path, token string) int {
+	t.Helper()
+
+	req, err := http.NewRequest("POST", s.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(&http.Cookie{Name: middleware.SessionCookieName, Value: token})
+	csrf := utils.NewSigner([]byte(strings.Repeat("s", 32))).Digest("csrf", token)
+	req.Header.Set(middleware.CSRFHeaderName, csrf)
+
+	client := *s.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestImpersonationCannotActForUser(t *testing.T) {
+	s := newTestServer(t)
+	_, admin := s.signUp(t, "root", models.RoleAdmin)
+	alice, aliceToken := s.signUp(t, "alice", models.RolePlayer)
+
+	var quest models.Quest
+	body := map[string]interface{}{"title": "Water the plants", "description": "All of them", "reward": 5}
+	if status := s.do(t, "POST", "/api/quest", admin, body, &quest); status != http.StatusOK {
+		t.Fatalf("create quest: status %d", status)
+	}
+
+	var impersonation struct {
+		Token string `json:"token"`
+	}
+	path := "/api/admin/users/" + strconv.Itoa(int(alice.ID)) + "/impersonate"
+	if status := s.do(t, "POST", path, admin, map[string]string{"reason": "support ticket"}, &impersonation); status != http.StatusCreated {
+		t.Fatalf("impersonate: status %d", status)
+	}
+	token := impersonation.Token
+
+	if status := s.do(t, "GET", "/api/get-info", token, nil, nil); status != http.StatusOK {
+		t.Errorf("reading as the user: status %d, want 200", status)
+	}
+	complete := map[string]interface{}{"quest_id": quest.ID, "user_id": alice.ID}
+	if status := s.do(t, "POST", "/api/quest-complete", token, complete, nil); status != http.StatusForbidden {
+		t.Errorf("completing a quest through the API: status %d, want 403", status)
+	}
+	if status := s.postAsSession(t, "/dashboard/quests/"+strconv.Itoa(int(quest.ID))+"/complete", token); status != http.StatusForbidden {
+		t.Errorf("completing a quest from the dashboard: status %d, want 403", status)
+	}
+
+	if status := s.postAsSession(t, "/logout", token); status != http.StatusSeeOther {
+		t.Errorf("logging out: status %d, want 303", status)
+	}
+	if status := s.do(t, "GET", "/api/get-info", aliceToken, nil, nil); status != http.StatusOK {
+		t.Errorf("user's own session after the admin logged out: status %d, want 200", status)
+	}
+}
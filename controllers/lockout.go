@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"test/lockout"
+	"test/logging"
+	"test/models"
+	"test/utils"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+var loginGuard = lockout.NewGuard(lockout.NewMemoryStore())
+
+func SetLoginGuard(guard *lockout.Guard) {
+	loginGuard = guard
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// loginAccountKey keys counters by user ID when the account exists so the
+// username and email of one account share a counter.
+func loginAccountKey(user *models.Users, identifier string) string {
+	if user != nil {
+		return "user:" + strconv.FormatUint(uint64(user.ID), 10)
+	}
+	return "identifier:" + strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// reserveLoginAttempt counts the attempt against the account and the client
+// address before the credentials are checked. It fails closed: when the
+// counters cannot be updated the attempt is refused.
+func reserveLoginAttempt(r *http.Request, account string) *loginFailure {
+	wait, err := loginGuard.Attempt(r.Context(), account, clientIP(r), time.Now())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to reserve login attempt", zap.Error(err))
+		return &loginFailure{status: http.StatusServiceUnavailable, message: "Login is temporarily unavailable, try again later"}
+	}
+	if wait > 0 {
+		logging.FromContext(r.Context()).Warn("Login throttled", zap.String("ip", clientIP(r)), zap.Duration("retryAfter", wait))
+		return &loginFailure{
+			status:     http.StatusTooManyRequests,
+			message:    "Too many failed login attempts, try again later",
+			retryAfter: wait,
+		}
+	}
+	return nil
+}
+
+func recordLoginSuccess(r *http.Request, account string) {
+	if err := loginGuard.Succeed(r.Context(), account, clientIP(r)); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to reset login attempts", zap.Error(err))
+	}
+}
+
+func requireAdmin(w http.ResponseWriter, r *http.Request) (models.Users, bool) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return user, false
+	}
+	if user.Role != models.RoleAdmin {
+		logging.FromContext(r.Context()).Warn("Forbidden admin action", zap.Uint("userID", user.ID))
+		utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
+		return user, false
+	}
+	return user, true
+}
+
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	var user models.Users
+	if err := models.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("User not found")
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := loginGuard.UnlockAccount(r.Context(), loginAccountKey(&user, "")); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to unlock user", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to unlock user")
+		return
+	}
+
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		if err := loginGuard.UnlockIP(r.Context(), ip); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to unlock address", zap.Error(err))
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to unlock address")
+			return
+		}
+	}
+
+	if err := recordAudit(models.DB, r, admin, "user.unlock", models.AuditTargetUser, user.ID, "", nil); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record audit entry", zap.Error(err))
+	}
+
+	logging.FromContext(r.Context()).Info("User unlocked", zap.Uint("userID", user.ID), zap.Uint("adminID", admin.ID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "User unlocked"})
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"test/logging"
+	"test/models"
+	"test/utils"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type LogLevelInput struct {
+	Level string `json:"level" validate:"required"`
+}
+
+func GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"level": logging.Level().String()})
+}
+
+// SetLogLevel changes the log level of the running process only; other
+// instances and restarts keep the configured level.
+func SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var input LogLevelInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	validate = validator.New()
+	if err := validate.Struct(input); err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
+		return
+	}
+
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(input.Level)); err != nil || level > zapcore.ErrorLevel {
+		utils.RespondWithError(w, http.StatusBadRequest, "Level must be debug, info, warn or error")
+		return
+	}
+
+	previous := logging.Level()
+	changes := map[string]models.Change{"log_level": {From: previous.String(), To: level.String()}}
+	if err := recordAudit(models.DB, r, admin, "log_level.set", models.AuditTargetSystem, 0, "", changes); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record audit entry", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	logging.SetLevel(level)
+	logging.FromContext(r.Context()).Warn("Log level changed", zap.Uint("adminID", admin.ID),
+		zap.Stringer("from", previous), zap.Stringer("to", level))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"level": level.String()})
+}
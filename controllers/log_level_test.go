@@ -0,0 +1,56 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strings"
+	"test/logging"
+	"test/models"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSetLogLevel(t *testing.T) {
+	s := newTestServer(t)
+	_, admin := s.signUp(t, "root", models.RoleAdmin)
+	_, player := s.signUp(t, "alice", models.RolePlayer)
+
+	initial := logging.Level()
+	t.Cleanup(func() { logging.SetLevel(initial) })
+
+	var out map[string]string
+	if status := s.do(t, "PUT", "/api/admin/log-level", admin, map[string]string{"level": "debug"}, &out); status != http.StatusOK {
+		t.Fatalf("set level: status %d", status)
+	}
+	if out["level"] != "debug" || logging.Level() != zapcore.DebugLevel {
+		t.Errorf("after setting debug: response %v, level %v", out, logging.Level())
+	}
+
+	out = nil
+	if status := s.do(t, "GET", "/api/admin/log-level", admin, nil, &out); status != http.StatusOK || out["level"] != "debug" {
+		t.Errorf("get level: status %d, response %v", status, out)
+	}
+
+	for _, level := range []string{"", "loud", "fatal"} {
+		if status := s.do(t, "PUT", "/api/admin/log-level", admin, map[string]string{"level": level}, nil); status != http.StatusBadRequest {
+			t.Errorf("level %q: status %d, want 400", level, status)
+		}
+	}
+	if status := s.do(t, "PUT", "/api/admin/log-level", player, map[string]string{"level": "error"}, nil); status != http.StatusForbidden {
+		t.Errorf("as a player: status %d, want 403", status)
+	}
+	if status := s.do(t, "PUT", "/api/admin/log-level", "", map[string]string{"level": "error"}, nil); status != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want 401", status)
+	}
+	if logging.Level() != zapcore.DebugLevel {
+		t.Errorf("rejected requests changed the level to %v", logging.Level())
+	}
+
+	var entries []models.AuditLog
+	if err := models.DB.Where("action = ?", "log_level.set").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].TargetType != models.AuditTargetSystem || !strings.Contains(entries[0].Changes, `"to":"debug"`) {
+		t.Errorf("audit entries = %+v, want one change to debug", entries)
+	}
+}
@@ -0,0 +1,86 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strings"
+	"test/logging/logtest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TestLogsOmitCredentials runs the flows that handle credentials and checks
+// that none of them reach the log output.
+func TestLogsOmitCredentials(t *testing.T) {
+	logs := logtest.Observe(t)
+	s := newTestServer(t)
+
+	s.register(t, "alice", "alice@example.com", "Secret123!")
+	msg, _ := s.mail.Last()
+	verification := verifyLink.FindString(msg.Body)
+	s.verify(t)
+	if _, status := s.login(t, "alice@example.com", "Wrong-Secret123!"); status != http.StatusUnauthorized {
+		t.Fatalf("login with wrong password: status %d", status)
+	}
+	token, status := s.login(t, "alice", "Secret123!")
+	if status != http.StatusOK {
+		t.Fatalf("login: status %d", status)
+	}
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	body := map[string]interface{}{"name": "ci", "scopes": []string{"quests:read"}}
+	if status := s.do(t, "POST", "/api/me/api-keys", token, body, &created); status != http.StatusCreated || created.Key == "" {
+		t.Fatalf("create API key: status %d", status)
+	}
+	get := func(path string, header map[string]string) int {
+		req, err := http.NewRequest("GET", s.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		resp, err := s.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := get("/api/quests", map[string]string{"X-API-Key": created.Key}); status != http.StatusOK {
+		t.Fatalf("list quests with API key: status %d", status)
+	}
+	get("/dashboard", map[string]string{"Authorization": token, "Cookie": "session=" + token + "; csrf=cookie-secret"})
+
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	var output strings.Builder
+	for _, entry := range logs.All() {
+		buf, err := encoder.EncodeEntry(entry.Entry, entry.Context)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output.WriteString(buf.String())
+		buf.Free()
+	}
+	if !strings.Contains(output.String(), "User created") {
+		t.Fatalf("registration was not logged:\n%s", output.String())
+	}
+
+	secrets := map[string]string{
+		"password":           "Secret123!",
+		"wrong password":     "Wrong-Secret123!",
+		"email":              "alice@example.com",
+		"session token":      token,
+		"API key":            created.Key,
+		"verification token": strings.TrimPrefix(verification, "/users/verify?token="),
+		"cookie":             "cookie-secret",
+	}
+	for name, secret := range secrets {
+		if strings.Contains(output.String(), secret) {
+			t.Errorf("logs contain the %s", name)
+		}
+	}
+}
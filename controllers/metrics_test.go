@@ -0,0 +1,41 @@
+package controllers_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestMetricsGroupUnknownMethods(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, method := range []string{"BREW", "PROPFIND"} {
+		req, err := http.NewRequest(method, s.URL+"/api/quests", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := s.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := s.Client().Get(s.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(body), `method="BREW"`) || strings.Contains(string(body), `method="PROPFIND"`) {
+		t.Error("/metrics has a series for a client-chosen method")
+	}
+	if !strings.Contains(string(body), `http_requests_total{method="OTHER"`) {
+		t.Error(`/metrics has no method="OTHER" series for the unknown methods`)
+	}
+}
@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"test/logging"
+	"test/metrics"
+	"test/middleware"
+	"test/models"
+	"test/utils"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer          = "Quest"
+	mfaChallengePurpose = "mfa-challenge"
+	mfaChallengeTTL     = 5 * time.Minute
+	recoveryCodeCount   = 10
+)
+
+var errInvalidSecondFactor = errors.New("invalid second factor")
+
+func currentUser(w http.ResponseWriter, r *http.Request) (models.Users, bool) {
+	var user models.Users
+
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Unauthorized")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return user, false
+	}
+
+	if err := models.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("User not found")
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return user, false
+	}
+	return user, true
+}
+
+func EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		logging.FromContext(r.Context()).Warn("TOTP already enabled", zap.Uint("userID", user.ID))
+		utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to generate TOTP secret", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if err := models.DB.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to store TOTP secret", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	uri := utils.TOTPURI(totpIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to render QR code", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+type TOTPCodeInput struct {
+	Code string `json:"code"`
+}
+
+func ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var input TOTPCodeInput
+
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to read request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read request body")
+		return
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if user.TOTPEnabled || user.TOTPSecret == "" {
+		logging.FromContext(r.Context()).Warn("No pending TOTP enrollment", zap.Uint("userID", user.ID))
+		utils.RespondWithError(w, http.StatusConflict, "No pending two-factor enrollment")
+		return
+	}
+
+	step, valid := utils.ValidateTOTP(user.TOTPSecret, strings.TrimSpace(input.Code), time.Now())
+	if !valid {
+		logging.FromContext(r.Context()).Warn("Invalid TOTP confirmation code", zap.Uint("userID", user.ID))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	var codes []string
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to enable TOTP", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("TOTP enabled", zap.Uint("userID", user.ID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+type DisableTOTPInput struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var input DisableTOTPInput
+
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to read request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read request body")
+		return
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !user.TOTPEnabled {
+		utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if !user.CheckPassword(input.Password) {
+		logging.FromContext(r.Context()).Warn("Invalid password for TOTP disable", zap.Uint("userID", user.ID))
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifySecondFactor(tx, &user, input.Code, input.RecoveryCode); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error
+	})
+	if err == errInvalidSecondFactor {
+		logging.FromContext(r.Context()).Warn("Invalid second factor for TOTP disable", zap.Uint("userID", user.ID))
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to disable TOTP", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("TOTP disabled", zap.Uint("userID", user.ID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issueMFAChallenge returns a short-lived token proving the password step
+// succeeded; it is only exchangeable for a JWT together with a valid code.
+func (h *Handler) issueMFAChallenge(user models.Users) string {
+	value := strconv.FormatUint(uint64(user.ID), 10) + ":" + strconv.FormatUint(uint64(user.SessionVersion), 10)
+	return h.signer.Sign(mfaChallengePurpose, value, time.Now().Add(mfaChallengeTTL))
+}
+
+type LoginMFAInput struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var input LoginMFAInput
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to read request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read request body")
+		return
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, failure := h.authenticateMFA(r, input)
+	if failure != nil {
+		failure.respond(w)
+		return
+	}
+
+	token, err := h.startSession(r.Context(), &user)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Failed Generate token")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
+
+// authenticateMFA exchanges an MFA challenge plus a TOTP or recovery code
+// for the user, counting failures like password attempts.
+func (h *Handler) authenticateMFA(r *http.Request, input LoginMFAInput) (models.Users, *loginFailure) {
+	var user models.Users
+
+	value, err := h.signer.Verify(mfaChallengePurpose, input.MFAToken, time.Now())
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid MFA challenge", zap.Error(err))
+		return user, &loginFailure{status: http.StatusUnauthorized, message: "Invalid or expired MFA challenge"}
+	}
+
+	rawID, rawVersion, _ := strings.Cut(value, ":")
+	if err := models.DB.Where("id = ?", rawID).First(&user).Error; err != nil ||
+		strconv.FormatUint(uint64(user.SessionVersion), 10) != rawVersion || !user.TOTPEnabled {
+		logging.FromContext(r.Context()).Warn("Stale MFA challenge")
+		return user, &loginFailure{status: http.StatusUnauthorized, message: "Invalid or expired MFA challenge"}
+	}
+
+	account := loginAccountKey(&user, "")
+	if failure := reserveLoginAttempt(r, account); failure != nil {
+		return user, failure
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		return verifySecondFactor(tx, &user, input.Code, input.RecoveryCode)
+	})
+	if err == errInvalidSecondFactor {
+		logging.FromContext(r.Context()).Warn("Invalid MFA code", zap.Uint("userID", user.ID))
+		metrics.LoginFailed()
+		return user, &loginFailure{status: http.StatusUnauthorized, message: "Invalid code"}
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to verify MFA code", zap.Error(err))
+		return user, &loginFailure{status: http.StatusInternalServerError, message: "Internal Server Error"}
+	}
+
+	recordLoginSuccess(r, account)
+	return user, nil
+}
+
+// verifySecondFactor accepts either a TOTP code newer than the last one used
+// or an unused recovery code, consuming whichever was presented.
+func verifySecondFactor(tx *gorm.DB, user *models.Users, code, recoveryCode string) error {
+	if code = strings.TrimSpace(code); code != "" {
+		step, valid := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !valid || step <= user.TOTPLastStep {
+			return errInvalidSecondFactor
+		}
+		result := tx.Model(&models.Users{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errInvalidSecondFactor
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	if recoveryCode = normalizeRecoveryCode(recoveryCode); recoveryCode != "" {
+		result := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, models.HashToken(recoveryCode)).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errInvalidSecondFactor
+		}
+		logging.Info("Recovery code used", zap.Uint("userID", user.ID))
+		return nil
+	}
+
+	return errInvalidSecondFactor
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 6)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))[:10]
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: models.HashToken(raw)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+	}
+	return codes, nil
+}
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"test/logging"
+	"test/metrics"
+	"test/models"
+	"test/oidc"
+	"test/utils"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcFlowCookie  = "oidc_flow"
+	oidcFlowPurpose = "oidc-flow"
+	oidcFlowTTL     = 10 * time.Minute
+)
+
+var usernameUnsafe = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// errServiceAccount stops an identity provider from signing in as, or
+// linking to, an account that may only use API keys.
+var errServiceAccount = errors.New("service accounts cannot sign in")
+
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+	provider, ok := oidc.Lookup(name)
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	state, errState := oidc.RandomString(24)
+	nonce, errNonce := oidc.RandomString(24)
+	verifier, challenge, errPKCE := oidc.NewPKCE()
+	if err := errors.Join(errState, errNonce, errPKCE); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to start OIDC flow", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("OIDC discovery failed", zap.String("provider", name), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadGateway, "Identity provider unavailable")
+		return
+	}
+
+	// The flow secrets travel in a signed cookie so no server-side state is
+	// needed between the redirect and the callback.
+	value := strings.Join([]string{name, state, nonce, verifier}, "|")
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    h.signer.Sign(oidcFlowPurpose, value, time.Now().Add(oidcFlowTTL)),
+		Path:     "/auth/oidc/",
+		MaxAge:   int(oidcFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+	provider, ok := oidc.Lookup(name)
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: oidcFlowCookie, Path: "/auth/oidc/", MaxAge: -1, HttpOnly: true})
+
+	if errCode := r.URL.Query().Get("error"); errCode != "" {
+		logging.FromContext(r.Context()).Warn("OIDC provider returned an error", zap.String("provider", name), zap.String("error", errCode))
+		utils.RespondWithError(w, http.StatusUnauthorized, "Sign-in was not completed")
+		return
+	}
+
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Sign-in session expired, please try again")
+		return
+	}
+	value, err := h.signer.Verify(oidcFlowPurpose, cookie.Value, time.Now())
+	parts := strings.Split(value, "|")
+	if err != nil || len(parts) != 4 || parts[0] != name || parts[1] != r.URL.Query().Get("state") {
+		logging.FromContext(r.Context()).Warn("OIDC state mismatch", zap.String("provider", name))
+		utils.RespondWithError(w, http.StatusBadRequest, "Sign-in session expired, please try again")
+		return
+	}
+	nonce, verifier := parts[2], parts[3]
+
+	claims, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), verifier, nonce)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("OIDC code exchange failed", zap.String("provider", name), zap.Error(err))
+		utils.RespondWithError(w, http.StatusUnauthorized, "Sign-in failed")
+		return
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		logging.FromContext(r.Context()).Warn("OIDC email not verified", zap.String("provider", name))
+		utils.RespondWithError(w, http.StatusForbidden, "Your identity provider has not verified your email address")
+		return
+	}
+
+	user, err := linkOrProvisionUser(name, claims)
+	if errors.Is(err, errServiceAccount) {
+		logging.FromContext(r.Context()).Warn("OIDC sign-in as service account", zap.String("provider", name), zap.Uint("userID", user.ID))
+		utils.RespondWithError(w, http.StatusForbidden, "Service accounts cannot sign in")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to link OIDC identity", zap.String("provider", name), zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Sign-in failed")
+		return
+	}
+
+	if user.TOTPEnabled {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    h.issueMFAChallenge(user),
+		})
+		return
+	}
+
+	token, err := h.startSession(r.Context(), &user)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Failed Generate token")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
+
+// linkOrProvisionUser finds the user already linked to this identity, links
+// an existing user with the same email, or creates a new account. An
+// unverified account with that email is reclaimed rather than trusted.
+// Service accounts are never linked or signed in.
+func linkOrProvisionUser(provider string, claims *oidc.Claims) (models.Users, error) {
+	var user models.Users
+	provisioned := false
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+		if err == nil {
+			if err := tx.Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+				return err
+			}
+			if user.Role == models.RoleService {
+				return errServiceAccount
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		err = tx.Where("email = ?", claims.Email).First(&user).Error
+		if err == nil && user.Role == models.RoleService {
+			return errServiceAccount
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			username, err := availableUsername(tx, claims.Email)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			user = models.Users{Username: username, Email: claims.Email, EmailVerifiedAt: &now}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			provisioned = true
+			logging.Info("User provisioned from OIDC", zap.Uint("userID", user.ID), zap.String("provider", provider))
+		} else if err != nil {
+			return err
+		} else if !user.IsVerified() {
+			if err := reclaimUnverifiedUser(tx, &user); err != nil {
+				return err
+			}
+			logging.Warn("Unverified account reclaimed by OIDC sign-in", zap.Uint("userID", user.ID), zap.String("provider", provider))
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if err == nil && provisioned {
+		metrics.UserRegistered("oidc")
+	}
+	return user, err
+}
+
+// reclaimUnverifiedUser hands an unverified account over to the person the
+// provider vouches for. Whoever registered it never proved they own the
+// address, so their password, second factor, API keys and sessions go.
+func reclaimUnverifiedUser(tx *gorm.DB, user *models.Users) error {
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	user.Password = ""
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	err := tx.Model(user).Updates(map[string]interface{}{
+		"email_verified_at": now,
+		"password":          "",
+		"totp_enabled":      false,
+		"totp_secret":       "",
+	}).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return revokeSessions(tx, user)
+}
+
+func availableUsername(tx *gorm.DB, email string) (string, error) {
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	base := usernameUnsafe.ReplaceAllString(local, "")
+	if base == "" {
+		base = "user"
+	}
+
+	for i := 0; i < 100; i++ {
+		candidate := base
+		if i > 0 {
+			candidate = fmt.Sprintf("%s%d", base, i+1)
+		}
+		var count int64
+		if err := tx.Model(&models.Users{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("no available username")
+}
+
+func GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(oidc.Names())
+}
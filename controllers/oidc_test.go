@@ -0,0 +1,140 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"test/models"
+	"test/oidc"
+	"test/oidc/oidctest"
+	"testing"
+	"time"
+)
+
+// signInWithOIDC runs the browser side of the authorization code flow against
+// a test provider that approves immediately as user.
+func signInWithOIDC(t *testing.T, s *testServer, user oidctest.User) (string, int) {
+	t.Helper()
+
+	idp, err := oidctest.NewServer("quests", user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(idp.Close)
+	oidc.Register(&oidc.Provider{
+		Name:        "test",
+		Issuer:      idp.Issuer(),
+		ClientID:    "quests",
+		RedirectURL: s.URL + "/auth/oidc/test/callback",
+	})
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := &http.Client{Jar: jar}
+	resp, err := browser.Get(s.URL + "/auth/oidc/test/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return out.Token, resp.StatusCode
+}
+
+func TestOIDCReclaimsUnverifiedAccount(t *testing.T) {
+	s := newTestServer(t)
+
+	// Someone registers the victim's address before the victim signs up.
+	squatter := s.register(t, "squatter", "victim@example.com", "Squatter1!")
+	squatterToken, status := s.login(t, "squatter", "Squatter1!")
+	if status != http.StatusOK {
+		t.Fatalf("squatter login: status %d", status)
+	}
+
+	token, status := signInWithOIDC(t, s, oidctest.User{Subject: "victim", Email: "victim@example.com", EmailVerified: true})
+	if status != http.StatusOK || token == "" {
+		t.Fatalf("OIDC sign-in: status %d", status)
+	}
+
+	if status := s.do(t, "GET", "/api/get-info", squatterToken, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("squatter session after OIDC sign-in: status %d, want 401", status)
+	}
+	if _, status := s.login(t, "squatter", "Squatter1!"); status != http.StatusUnauthorized {
+		t.Errorf("squatter password after OIDC sign-in: status %d, want 401", status)
+	}
+
+	var user models.Users
+	if err := models.DB.First(&user, squatter.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsVerified() || user.Password != "" {
+		t.Errorf("reclaimed account: verified=%v password set=%v", user.IsVerified(), user.Password != "")
+	}
+	if status := s.do(t, "GET", "/api/get-info", token, nil, nil); status != http.StatusOK {
+		t.Errorf("OIDC session: status %d", status)
+	}
+}
+
+func TestOIDCLinksVerifiedAccount(t *testing.T) {
+	s := newTestServer(t)
+
+	s.register(t, "alice", "alice@example.com", "Secret123!")
+	s.verify(t)
+	aliceToken, _ := s.login(t, "alice", "Secret123!")
+
+	token, status := signInWithOIDC(t, s, oidctest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true})
+	if status != http.StatusOK || token == "" {
+		t.Fatalf("OIDC sign-in: status %d", status)
+	}
+
+	if status := s.do(t, "GET", "/api/get-info", aliceToken, nil, nil); status != http.StatusOK {
+		t.Errorf("existing session after linking: status %d, want 200", status)
+	}
+	if _, status := s.login(t, "alice", "Secret123!"); status != http.StatusOK {
+		t.Errorf("password after linking: status %d, want 200", status)
+	}
+}
+
+func TestOIDCRejectsUnverifiedProviderEmail(t *testing.T) {
+	s := newTestServer(t)
+
+	_, status := signInWithOIDC(t, s, oidctest.User{Subject: "bob", Email: "bob@example.com"})
+	if status != http.StatusForbidden {
+		t.Errorf("status %d, want 403", status)
+	}
+}
+
+func TestOIDCRefusesServiceAccounts(t *testing.T) {
+	s := newTestServer(t)
+
+	now := time.Now()
+	byEmail := models.Users{Username: "ci", Email: "ci@example.com", Role: models.RoleService, EmailVerifiedAt: &now}
+	linked := models.Users{Username: "sync", Email: "sync@example.com", Role: models.RoleService, EmailVerifiedAt: &now}
+	for _, account := range []*models.Users{&byEmail, &linked} {
+		if err := models.DB.Create(account).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Linked before the account was turned into a service account.
+	if err := models.DB.Create(&models.UserIdentity{UserID: linked.ID, Provider: "test", Subject: "sync", Email: linked.Email}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, status := signInWithOIDC(t, s, oidctest.User{Subject: "ci", Email: "ci@example.com", EmailVerified: true}); status != http.StatusForbidden {
+		t.Errorf("sign-in by email: status %d, want 403", status)
+	}
+	var identities int64
+	models.DB.Model(&models.UserIdentity{}).Where("user_id = ?", byEmail.ID).Count(&identities)
+	if identities != 0 {
+		t.Errorf("service account was linked to %d identities", identities)
+	}
+
+	if _, status := signInWithOIDC(t, s, oidctest.User{Subject: "sync", Email: "other@example.com", EmailVerified: true}); status != http.StatusForbidden {
+		t.Errorf("sign-in by linked identity: status %d, want 403", status)
+	}
+}
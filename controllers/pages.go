@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"test/logging"
+	"test/templates"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var pageFuncs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("2 Jan 2006 15:04") },
+	"inc":  func(i int) int { return i + 1 },
+}
+
+// pages holds every server-rendered page, parsed once at startup from the
+// embedded templates.
+var pages = parsePages("layout.html", "login.html", "reset_password.html", "dashboard.html",
+	"admin_users.html", "admin_user.html", "admin_quests.html", "admin_inventory.html", "admin_audit.html")
+
+func parsePages(layout string, names ...string) map[string]*template.Template {
+	base := template.Must(template.New(layout).Funcs(pageFuncs).ParseFS(templates.FS, layout))
+
+	parsed := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		page := template.Must(base.Clone())
+		parsed[name] = template.Must(page.ParseFS(templates.FS, name))
+	}
+	return parsed
+}
+
+// renderPage executes into a buffer first so a template error results in a
+// clean 500 rather than a half-written page.
+func renderPage(w http.ResponseWriter, status int, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := pages[name].Execute(&buf, data); err != nil {
+		logging.Error("Failed to render page", zap.String("page", name), zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"test/logging"
+	"test/mail"
+	"test/middleware"
+	"test/models"
+	"test/utils"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	passwordResetTTL      = time.Hour
+	passwordResetCooldown = time.Minute
+)
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// revokeSessions bumps the session version so every previously issued token
+// is rejected by AuthMiddleware.
+func revokeSessions(tx *gorm.DB, user *models.Users) error {
+	user.SessionVersion++
+	user.Token = ""
+	return tx.Model(user).Updates(map[string]interface{}{
+		"session_version": user.SessionVersion,
+		"token":           "",
+	}).Error
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" validate:"required"`
+}
+
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input ForgotPasswordInput
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to read request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read request body")
+		return
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil || input.Email == "" {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Always answer the same way so the endpoint cannot reveal which
+	// addresses have accounts.
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"message": "If the account exists, a reset link has been sent"})
+	}()
+
+	var user models.Users
+	if err := models.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+		return
+	}
+
+	var recent int64
+	models.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-passwordResetCooldown)).
+		Count(&recent)
+	if recent > 0 {
+		logging.FromContext(r.Context()).Warn("Password reset throttled", zap.Uint("userID", user.ID))
+		return
+	}
+
+	if err := h.issuePasswordReset(r.Context(), user); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to issue password reset", zap.Uint("userID", user.ID), zap.Error(err))
+	}
+}
+
+func (h *Handler) issuePasswordReset(ctx context.Context, user models.Users) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the newest link is usable.
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: models.HashToken(token),
+			ExpiresAt: now.Add(passwordResetTTL),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := h.baseURL + "/password/reset?token=" + url.QueryEscape(token)
+	return mail.Enqueue(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password:\n\n%s\n\nReset token: %s\n\nThe link expires in %s. If you did not request this, you can ignore this email.\n",
+			user.Username, link, token, passwordResetTTL),
+	})
+}
+
+var errInvalidReset = errors.New("invalid or expired reset token")
+
+// resetPassword redeems a reset token, sets the new password and signs the
+// user out everywhere.
+func resetPassword(ctx context.Context, token, newPassword string) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		now := time.Now()
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", models.HashToken(token), now).
+			First(&reset).Error; err != nil {
+			return errInvalidReset
+		}
+
+		// Claiming the token with a conditional update keeps it single-use
+		// even if two requests race.
+		claimed := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected != 1 {
+			return errInvalidReset
+		}
+
+		var user models.Users
+		if err := tx.Where("id = ?", reset.UserID).First(&user).Error; err != nil {
+			return errInvalidReset
+		}
+		if err := user.SetPassword(newPassword); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("password", user.Password).Error; err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Info("Password reset", zap.Uint("userID", user.ID))
+		return revokeSessions(tx, &user)
+	})
+}
+
+type ResetPasswordInput struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input ResetPasswordInput
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to read request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read request body")
+		return
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	validate = validator.New()
+	if err := validate.Struct(input); err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
+		return
+	}
+
+	err = resetPassword(r.Context(), input.Token, input.NewPassword)
+	if err == errInvalidReset {
+		logging.FromContext(r.Context()).Warn("Invalid password reset token")
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to reset password", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password has been reset"})
+}
+
+// resetPage is the data rendered into templates/reset_password.html.
+type resetPage struct {
+	Token string
+	Error string
+	Done  bool
+}
+
+// ResetPasswordHTML serves the page the reset email links to. The token in
+// the form doubles as its CSRF protection.
+func ResetPasswordHTML(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, http.StatusOK, "reset_password.html", resetPage{Token: r.URL.Query().Get("token")})
+}
+
+func ResetPasswordForm(w http.ResponseWriter, r *http.Request) {
+	input := ResetPasswordInput{
+		Token:       r.PostFormValue("token"),
+		NewPassword: r.PostFormValue("new_password"),
+	}
+	fail := func(status int, message string) {
+		renderPage(w, status, "reset_password.html", resetPage{Token: input.Token, Error: message})
+	}
+
+	validate = validator.New()
+	if err := validate.Struct(input); err != nil {
+		fail(http.StatusBadRequest, "The new password must be at least 8 characters long")
+		return
+	}
+	if input.NewPassword != r.PostFormValue("confirm_password") {
+		fail(http.StatusBadRequest, "The passwords do not match")
+		return
+	}
+
+	err := resetPassword(r.Context(), input.Token, input.NewPassword)
+	if err == errInvalidReset {
+		logging.FromContext(r.Context()).Warn("Invalid password reset token")
+		fail(http.StatusBadRequest, "This reset link is invalid or has expired")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to reset password", zap.Error(err))
+		fail(http.StatusInternalServerError, "Failed to reset password, please try again")
+		return
+	}
+
+	renderPage(w, http.StatusOK, "reset_password.html", resetPage{Done: true})
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var input ChangePasswordInput
+
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Unauthorized")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to read request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read request body")
+		return
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	validate = validator.New()
+	if err := validate.Struct(input); err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
+		return
+	}
+
+	var user models.Users
+	if err := models.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("User not found")
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// Wrong current passwords count towards the login lockout, so a stolen
+	// session cannot be used to guess the password here instead.
+	account := loginAccountKey(&user, "")
+	if failure := reserveLoginAttempt(r, account); failure != nil {
+		failure.respond(w)
+		return
+	}
+	if !user.CheckPassword(input.CurrentPassword) {
+		logging.FromContext(r.Context()).Warn("Invalid current password", zap.Uint("userID", user.ID))
+		utils.RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+	recordLoginSuccess(r, account)
+
+	if err := user.SetPassword(input.NewPassword); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to hash password", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", user.Password).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, &user)
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to change password", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	token, err := h.generateJWTToken(user)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Failed Generate token")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	if err := h.users.SetToken(r.Context(), user.ID, token); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to save token", zap.Error(err))
+	}
+
+	logging.FromContext(r.Context()).Info("Password changed", zap.Uint("userID", user.ID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
@@ -0,0 +1,29 @@
+package controllers_test
+
+import (
+	"net/http"
+	"test/models"
+	"testing"
+)
+
+func TestChangePasswordThrottlesWrongCurrentPassword(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp(t, "alice", models.RolePlayer)
+
+	change := func(current string) int {
+		body := map[string]string{"current_password": current, "new_password": "Changed123!"}
+		return s.do(t, "POST", "/api/me/password", token, body, nil)
+	}
+	// The account policy allows three free failures before delaying.
+	for i := 0; i < 4; i++ {
+		if status := change("Guess123!"); status != http.StatusUnauthorized {
+			t.Fatalf("guess %d: status %d, want 401", i+1, status)
+		}
+	}
+	if status := change("Secret123!"); status != http.StatusTooManyRequests {
+		t.Errorf("after four wrong guesses: status %d, want 429", status)
+	}
+	if _, status := s.login(t, "alice", "Secret123!"); status != http.StatusTooManyRequests {
+		t.Errorf("login after four wrong guesses: status %d, want 429", status)
+	}
+}
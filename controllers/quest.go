@@ -1,40 +1,130 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strconv"
 	"test/logging"
+	"test/metrics"
 	"test/middleware"
 	"test/models"
+	"test/repository"
 	"test/utils"
 	"time"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
 var validate *validator.Validate
 
-func GetAllQuests(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetAllQuests(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	var quests []models.Quest
-	models.DB.Find(&quests)
+	filter := repository.QuestFilter{Status: models.QuestActive}
+	params := r.URL.Query()
+
+	if difficulty := models.Difficulty(params.Get("difficulty")); difficulty != "" {
+		if !difficulty.Valid() {
+			logging.FromContext(r.Context()).Warn("Invalid difficulty filter")
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid difficulty")
+			return
+		}
+		filter.Difficulty = difficulty
+	}
+
+	if raw := params.Get("category"); raw != "" {
+		categoryID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			logging.FromContext(r.Context()).Warn("Invalid category filter")
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid category")
+			return
+		}
+		id := uint(categoryID)
+		filter.CategoryID = &id
+	}
+
+	filter.Tags = splitTagNames(params.Get("tags"))
+
+	quests, err := h.quests.List(r.Context(), filter)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to query quests", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
 
 	json.NewEncoder(w).Encode(quests)
 }
 
-func GetQuest(w http.ResponseWriter, r *http.Request) {
+type NearbyQuest struct {
+	models.Quest
+	Distance float64 `json:"distance"`
+}
+
+const (
+	defaultNearbyRadius = 1000.0
+	maxNearbyRadius     = 50000.0
+)
+
+func (h *Handler) GetNearbyQuests(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	id := mux.Vars(r)["id"]
-	var quest models.Quest
+	query := r.URL.Query()
+	lat, errLat := strconv.ParseFloat(query.Get("lat"), 64)
+	lng, errLng := strconv.ParseFloat(query.Get("lng"), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		logging.FromContext(r.Context()).Warn("Invalid coordinates")
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid coordinates")
+		return
+	}
+
+	radius := defaultNearbyRadius
+	if raw := query.Get("radius"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 || parsed > maxNearbyRadius {
+			logging.FromContext(r.Context()).Warn("Invalid radius")
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid radius")
+			return
+		}
+		radius = parsed
+	}
+
+	minLat, maxLat, minLng, maxLng := models.BoundingBox(lat, lng, radius)
+
+	bounds := repository.Bounds{MinLat: minLat, MaxLat: maxLat, MinLng: minLng, MaxLng: maxLng}
+	candidates, err := h.quests.ListGeofenced(r.Context(), bounds)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to query nearby quests", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	nearby := []NearbyQuest{}
+	for _, quest := range candidates {
+		if distance := quest.Geofence.DistanceTo(lat, lng); distance <= radius {
+			nearby = append(nearby, NearbyQuest{Quest: quest, Distance: distance})
+		}
+	}
+	sort.Slice(nearby, func(i, j int) bool { return nearby[i].Distance < nearby[j].Distance })
 
-	if err := models.DB.Where("id = ?", id).First(&quest).Error; err != nil {
-		logging.Warn("Quest not found")
+	json.NewEncoder(w).Encode(nearby)
+}
+
+func (h *Handler) GetQuest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := pathID(r)
+	var quest models.Quest
+	if err == nil {
+		quest, err = h.quests.Get(r.Context(), id)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Quest not found")
 		utils.RespondWithError(w, http.StatusNotFound, "Quest not found")
 		return
 	}
@@ -43,31 +133,64 @@ func GetQuest(w http.ResponseWriter, r *http.Request) {
 }
 
 type QuestInput struct {
-	Title       string `json:"title" validate:"required"`
-	Description string `json:"description" validate:"required"`
-	Reward      int    `json:"reward" validate:"required"`
+	Title       string           `json:"title" validate:"required"`
+	Description string           `json:"description" validate:"required"`
+	Reward      int              `json:"reward" validate:"required"`
+	Geofence    *models.Geofence `json:"geofence"`
+	Difficulty  string           `json:"difficulty"`
+	CategoryID  *uint            `json:"category_id"`
+	Tags        []string         `json:"tags"`
+}
+
+func (input QuestInput) classification(ctx context.Context, quests repository.QuestRepository) (models.Difficulty, []models.Tag, error) {
+	difficulty := models.Difficulty(input.Difficulty)
+	if difficulty == "" {
+		difficulty = models.DifficultyMedium
+	}
+	if !difficulty.Valid() {
+		return "", nil, errors.New("Invalid difficulty")
+	}
+
+	if input.CategoryID != nil {
+		if exists, err := quests.CategoryExists(ctx, *input.CategoryID); err != nil || !exists {
+			return "", nil, errors.New("Category not found")
+		}
+	}
+
+	tags, err := quests.ResolveTags(ctx, input.Tags)
+	if err != nil {
+		return "", nil, errors.New("Invalid tags")
+	}
+	return difficulty, tags, nil
 }
 
-func CreateQuest(w http.ResponseWriter, r *http.Request) {
+func (input QuestInput) geofence() (models.Geofence, error) {
+	if input.Geofence == nil {
+		return models.Geofence{}, nil
+	}
+	return *input.Geofence, input.Geofence.Validate()
+}
+
+func (h *Handler) CreateQuest(w http.ResponseWriter, r *http.Request) {
 	var input QuestInput
 
 	userID, err := middleware.GetUserIdFromToken(r)
 	if err != nil {
-		logging.Error("Unauthorized", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Unauthorized", zap.Error(err))
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		logging.Error("Internal Server Error", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
 	err = json.Unmarshal(body, &input)
 	if err != nil {
-		logging.Error("Invalid request body", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
@@ -75,21 +198,39 @@ func CreateQuest(w http.ResponseWriter, r *http.Request) {
 	validate = validator.New()
 	err = validate.Struct(input)
 	if err != nil {
-		logging.Error(err.Error(), zap.Error(err))
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
 		return
 	}
 
+	geofence, err := input.geofence()
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid geofence", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	difficulty, tags, err := input.classification(r.Context(), h.quests)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid quest classification", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	quest := &models.Quest{
 		Title:       input.Title,
 		Description: input.Description,
 		Reward:      input.Reward,
 		UserID:      userID,
+		Geofence:    geofence,
+		Difficulty:  difficulty,
+		CategoryID:  input.CategoryID,
+		Tags:        tags,
 	}
 
-	err = models.DB.Create(quest).Error
+	err = h.quests.Create(r.Context(), quest)
 	if err != nil {
-		logging.Error(err.Error(), zap.Error(err))
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create quest")
 		return
 	}
@@ -98,14 +239,16 @@ func CreateQuest(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(quest)
 }
 
-func UpdateQuest(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateQuest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	id := mux.Vars(r)["id"]
+	id, err := pathID(r)
 	var quest models.Quest
-
-	if err := models.DB.Where("id = ?", id).First(&quest).Error; err != nil {
-		logging.Warn("Quest not found")
+	if err == nil {
+		quest, err = h.quests.Get(r.Context(), id)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Quest not found")
 		utils.RespondWithError(w, http.StatusNotFound, "Quest not found")
 		return
 	}
@@ -116,68 +259,132 @@ func UpdateQuest(w http.ResponseWriter, r *http.Request) {
 	_ = json.Unmarshal(body, &input)
 
 	validate = validator.New()
-	err := validate.Struct(input)
+	err = validate.Struct(input)
 
 	if err != nil {
-		logging.Error(err.Error(), zap.Error(err))
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
 		return
 	}
 
+	if input.Geofence != nil {
+		geofence, err := input.geofence()
+		if err != nil {
+			logging.FromContext(r.Context()).Warn("Invalid geofence", zap.Error(err))
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		quest.Geofence = geofence
+	}
+
+	difficulty, tags, err := input.classification(r.Context(), h.quests)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid quest classification", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	quest.Title = input.Title
 	quest.Description = input.Description
 	quest.Reward = input.Reward
+	quest.Difficulty = difficulty
+	quest.CategoryID = input.CategoryID
 
-	models.DB.Save(&quest)
+	if input.Tags == nil {
+		tags = nil
+	}
+	if err := h.quests.Update(r.Context(), &quest, tags); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to update quest", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update quest")
+		return
+	}
 
 	json.NewEncoder(w).Encode(quest)
 }
 
-func DeleteQuest(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+func (h *Handler) CloneQuest(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Unauthorized", zap.Error(err))
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 
-	id := mux.Vars(r)["id"]
+	id, err := pathID(r)
 	var quest models.Quest
-
-	if err := models.DB.Where("id = ?", id).First(&quest).Error; err != nil {
-		logging.Warn("Quest not fouund")
+	if err == nil {
+		quest, err = h.quests.Get(r.Context(), id)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Quest not found")
 		utils.RespondWithError(w, http.StatusNotFound, "Quest not found")
 		return
 	}
 
-	models.DB.Delete(&quest)
+	clone := quest.Clone(userID)
+	if err := h.quests.Create(r.Context(), clone); err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to clone quest")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(clone)
+}
+
+func (h *Handler) DeleteQuest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := pathID(r)
+	if err == nil {
+		err = h.quests.Delete(r.Context(), id)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		logging.FromContext(r.Context()).Warn("Quest not fouund")
+		utils.RespondWithError(w, http.StatusNotFound, "Quest not found")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete quest", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete quest")
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
-	json.NewEncoder(w).Encode(quest)
 }
 
 type InputQuestComplete struct {
-	QuestId int `json:"quest_id" validate:"required"`
-	UserId  int `json:"user_id" validate:"required"`
+	QuestId   int      `json:"quest_id" validate:"required"`
+	UserId    int      `json:"user_id" validate:"required"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Accuracy  float64  `json:"accuracy" validate:"gte=0"`
 }
 
-func QuestComplete(w http.ResponseWriter, r *http.Request) {
+// maxCheckInAccuracy rejects location readings too vague to prove a visit.
+const maxCheckInAccuracy = 100.0
+
+func (h *Handler) QuestComplete(w http.ResponseWriter, r *http.Request) {
 	var input InputQuestComplete
-	var quest models.Quest
-	var user models.Users
 
 	userID, err := middleware.GetUserIdFromToken(r)
 	if err != nil {
-		logging.Error("Unauthorized", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Unauthorized", zap.Error(err))
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		logging.Error("Internal Server Error", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
 	err = json.Unmarshal(body, &input)
 	if err != nil {
-		logging.Error("Invalid Json", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Invalid Json", zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
@@ -185,37 +392,69 @@ func QuestComplete(w http.ResponseWriter, r *http.Request) {
 	validate = validator.New()
 	err = validate.Struct(input)
 	if err != nil {
-		logging.Error("Validation Erorr", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Validation Erorr", zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
 		return
 	}
 
-	if err := models.DB.Where("id = ?", input.QuestId).First(&quest).Error; err != nil {
-		logging.Warn("Quest not found")
+	quest, err := h.quests.GetActive(r.Context(), uint(input.QuestId))
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Quest not found")
 		utils.RespondWithError(w, http.StatusNotFound, "Quest not found")
 		return
 	}
 
-	if err := models.DB.Where("id = ?", userID).First(&user).Error; err != nil {
-		logging.Warn("User not found")
+	if quest.Geofence.Enabled() {
+		if input.Latitude == nil || input.Longitude == nil {
+			logging.FromContext(r.Context()).Warn("Missing check-in location", zap.Uint("questID", quest.ID))
+			utils.RespondWithError(w, http.StatusBadRequest, "Location is required for this quest")
+			return
+		}
+		if input.Accuracy > maxCheckInAccuracy {
+			logging.FromContext(r.Context()).Warn("Check-in location too inaccurate", zap.Float64("accuracy", input.Accuracy))
+			utils.RespondWithError(w, http.StatusUnprocessableEntity, "Location accuracy is too low")
+			return
+		}
+		if !quest.Geofence.Contains(*input.Latitude, *input.Longitude) {
+			logging.FromContext(r.Context()).Warn("Check-in outside geofence", zap.Uint("questID", quest.ID))
+			utils.RespondWithError(w, http.StatusForbidden, "You are not within the quest area")
+			return
+		}
+	}
+
+	user, err := h.users.Get(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("User not found")
 		utils.RespondWithError(w, http.StatusNotFound, "User not found")
 		return
 	}
 
-	user.Point += quest.Reward
+	if !requireVerified(w, user) {
+		return
+	}
 
-	completeQuest := &models.CompletedQuest{
-		UserID:      userID,
-		QuestID:     quest.ID,
-		CompletedAt: time.Now(),
+	completed, err := h.users.HasCompleted(r.Context(), user.ID, quest.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to check quest completion", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if completed {
+		logging.FromContext(r.Context()).Warn("Quest already completed", zap.Uint("questID", quest.ID))
+		utils.RespondWithError(w, http.StatusConflict, "You have already completed this quest")
+		return
 	}
 
-	user.AppendCompletedQuest(*completeQuest)
-	if err := models.DB.Save(&user).Error; err != nil {
-		logging.Error("Failed to update user", zap.Error(err))
+	if err := h.users.AwardQuest(r.Context(), &user, quest, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrAlreadyCompleted) {
+			utils.RespondWithError(w, http.StatusConflict, "You have already completed this quest")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to update user", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update user")
 		return
 	}
+	metrics.QuestCompleted(quest.Reward)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
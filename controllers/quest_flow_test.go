@@ -0,0 +1,92 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strconv"
+	"test/models"
+	"testing"
+)
+
+func TestRegisterLoginCreateAndCompleteQuest(t *testing.T) {
+	s := newTestServer(t)
+
+	registered := s.register(t, "alice", "alice@example.com", "Secret123!")
+	s.verify(t)
+
+	if _, status := s.login(t, "alice", "wrong-password"); status != http.StatusUnauthorized {
+		t.Fatalf("login with wrong password: status %d, want 401", status)
+	}
+	token, status := s.login(t, "alice@example.com", "Secret123!")
+	if status != http.StatusOK || token == "" {
+		t.Fatalf("login: status %d, token %q", status, token)
+	}
+
+	var quest models.Quest
+	body := map[string]interface{}{"title": "Visit the library", "description": "Borrow a book", "reward": 40}
+	if status := s.do(t, "POST", "/api/quest", token, body, &quest); status != http.StatusOK {
+		t.Fatalf("create quest: status %d", status)
+	}
+	if quest.ID == 0 || quest.UserID != registered.ID {
+		t.Fatalf("created quest = %+v, want an ID owned by user %d", quest, registered.ID)
+	}
+
+	complete := map[string]interface{}{"quest_id": quest.ID, "user_id": registered.ID}
+	var completed models.Users
+	if status := s.do(t, "POST", "/api/quest-complete", token, complete, &completed); status != http.StatusOK {
+		t.Fatalf("complete quest: status %d", status)
+	}
+	if completed.Point != quest.Reward {
+		t.Errorf("points after completion = %d, want %d", completed.Point, quest.Reward)
+	}
+
+	if status := s.do(t, "POST", "/api/quest-complete", token, complete, nil); status != http.StatusConflict {
+		t.Errorf("completing twice: status %d, want 409", status)
+	}
+
+	var profile models.Users
+	if status := s.do(t, "GET", "/api/get-info", token, nil, &profile); status != http.StatusOK {
+		t.Fatalf("get info: status %d", status)
+	}
+	if profile.Point != quest.Reward || len(profile.CompletedQuests) != 1 || profile.CompletedQuests[0].QuestID != quest.ID {
+		t.Errorf("profile = %d points, completions %+v; want %d points and quest %d", profile.Point, profile.CompletedQuests, quest.Reward, quest.ID)
+	}
+}
+
+func TestQuestCompletionRequiresSession(t *testing.T) {
+	s := newTestServer(t)
+
+	complete := map[string]interface{}{"quest_id": 1, "user_id": 1}
+	if status := s.do(t, "POST", "/api/quest-complete", "", complete, nil); status != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want 401", status)
+	}
+	if status := s.do(t, "POST", "/api/quest-complete", "not-a-token", complete, nil); status != http.StatusUnauthorized {
+		t.Errorf("with a malformed token: status %d, want 401", status)
+	}
+}
+
+func TestDeleteTaggedAndAssignedQuest(t *testing.T) {
+	s := newTestServer(t)
+	alice, token := s.signUp(t, "alice", models.RoleManager)
+
+	var quest models.Quest
+	body := map[string]interface{}{"title": "Walk the dog", "description": "Around the park", "reward": 10, "tags": []string{"outdoor"}}
+	if status := s.do(t, "POST", "/api/quest", token, body, &quest); status != http.StatusOK {
+		t.Fatalf("create quest: status %d", status)
+	}
+	assignment := models.QuestAssignment{QuestID: quest.ID, AssigneeID: alice.ID, AssignedByID: alice.ID, Status: models.AssignmentAssigned}
+	if err := models.DB.Create(&assignment).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/api/quest/" + strconv.Itoa(int(quest.ID))
+	if status := s.do(t, "DELETE", path, token, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete quest: status %d, want 204", status)
+	}
+	if status := s.do(t, "GET", path, token, nil, nil); status != http.StatusNotFound {
+		t.Errorf("get deleted quest: status %d, want 404", status)
+	}
+	var tag models.Tag
+	if err := models.DB.Where("name = ?", "outdoor").First(&tag).Error; err != nil {
+		t.Errorf("tag was removed with the quest: %v", err)
+	}
+}
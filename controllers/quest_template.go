@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"test/logging"
+	"test/middleware"
+	"test/models"
+	"test/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type QuestTemplateResponse struct {
+	models.QuestTemplate
+	Variables []string `json:"variables"`
+}
+
+func newQuestTemplateResponse(template models.QuestTemplate) QuestTemplateResponse {
+	return QuestTemplateResponse{QuestTemplate: template, Variables: template.Variables()}
+}
+
+func GetAllQuestTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var templates []models.QuestTemplate
+	if err := models.DB.Preload("Tags").Find(&templates).Error; err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load quest templates", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	response := make([]QuestTemplateResponse, 0, len(templates))
+	for _, template := range templates {
+		response = append(response, newQuestTemplateResponse(template))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetQuestTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	var template models.QuestTemplate
+
+	if err := models.DB.Preload("Tags").Where("id = ?", id).First(&template).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("Quest template not found")
+		utils.RespondWithError(w, http.StatusNotFound, "Quest template not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(newQuestTemplateResponse(template))
+}
+
+type QuestTemplateInput struct {
+	Name string `json:"name" validate:"required"`
+	QuestInput
+}
+
+func (h *Handler) CreateQuestTemplate(w http.ResponseWriter, r *http.Request) {
+	var input QuestTemplateInput
+
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Unauthorized", zap.Error(err))
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	err = json.Unmarshal(body, &input)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	validate = validator.New()
+	err = validate.Struct(input)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
+		return
+	}
+
+	geofence, err := input.geofence()
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid geofence", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	difficulty, tags, err := input.classification(r.Context(), h.quests)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid quest classification", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template := &models.QuestTemplate{
+		Name:        input.Name,
+		Title:       input.Title,
+		Description: input.Description,
+		Reward:      input.Reward,
+		UserID:      userID,
+		Geofence:    geofence,
+		Difficulty:  difficulty,
+		CategoryID:  input.CategoryID,
+		Tags:        tags,
+	}
+
+	if err := models.DB.Create(template).Error; err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create quest template")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newQuestTemplateResponse(*template))
+}
+
+type InstantiateTemplateInput struct {
+	Variables map[string]string `json:"variables"`
+}
+
+func (h *Handler) InstantiateQuestTemplate(w http.ResponseWriter, r *http.Request) {
+	var input InstantiateTemplateInput
+
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Unauthorized", zap.Error(err))
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	var template models.QuestTemplate
+
+	if err := models.DB.Preload("Tags").Where("id = ?", id).First(&template).Error; err != nil {
+		logging.FromContext(r.Context()).Warn("Quest template not found")
+		utils.RespondWithError(w, http.StatusNotFound, "Quest template not found")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &input); err != nil {
+			logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+			return
+		}
+	}
+
+	quest, err := template.Instantiate(userID, input.Variables)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to instantiate quest template", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.quests.Create(r.Context(), quest); err != nil {
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create quest")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quest)
+}
@@ -0,0 +1,140 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"test/config"
+	"test/controllers"
+	"test/lockout"
+	"test/mail"
+	"test/models"
+	"test/repository"
+	"testing"
+)
+
+// testServer runs the full router against a fresh in-memory SQLite database.
+type testServer struct {
+	*httptest.Server
+	mail *mail.MemorySender
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.Path = ":memory:"
+	cfg.Auth.JWTSecret = strings.Repeat("s", 32)
+	if err := models.ConnectDatabase(cfg.Database); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { models.CloseDatabase() })
+
+	sender := mail.NewMemorySender()
+	mail.SetSender(sender)
+	controllers.SetLoginGuard(lockout.NewGuard(lockout.NewMemoryStore()))
+
+	srv := httptest.NewUnstartedServer(nil)
+	cfg.Server.BaseURL = "http://" + srv.Listener.Addr().String()
+	srv.Config.Handler = controllers.New(controllers.Deps{
+		Config:       cfg,
+		Repositories: repository.NewGorm(models.DB),
+	})
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	return &testServer{Server: srv, mail: sender}
+}
+
+// do sends body as JSON, with token in the Authorization header when set,
+// and decodes the JSON response into out when it is not nil.
+func (s *testServer) do(t *testing.T, method, path, token string, body, out interface{}) int {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, s.URL+path, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func (s *testServer) register(t *testing.T, username, email, password string) models.Users {
+	t.Helper()
+
+	var user models.Users
+	body := map[string]string{"username": username, "email": email, "password": password}
+	if status := s.do(t, "POST", "/users/register", "", body, &user); status != http.StatusOK {
+		t.Fatalf("register %s: status %d", username, status)
+	}
+	return user
+}
+
+var verifyLink = regexp.MustCompile(`/users/verify\?token=\S+`)
+
+// verify opens the link from the last verification email.
+func (s *testServer) verify(t *testing.T) {
+	t.Helper()
+
+	msg, ok := s.mail.Last()
+	link := verifyLink.FindString(msg.Body)
+	if !ok || link == "" {
+		t.Fatal("no verification email sent")
+	}
+	if status := s.do(t, "GET", link, "", nil, nil); status != http.StatusOK {
+		t.Fatalf("verify: status %d", status)
+	}
+}
+
+// login returns the session token, or "" with the status when login fails.
+func (s *testServer) login(t *testing.T, identifier, password string) (string, int) {
+	t.Helper()
+
+	var out struct {
+		Token string `json:"token"`
+	}
+	body := map[string]string{"login_identifier": identifier, "password": password}
+	status := s.do(t, "POST", "/users/login", "", body, &out)
+	return out.Token, status
+}
+
+// signUp registers and verifies an account with the given role and returns
+// its session token.
+func (s *testServer) signUp(t *testing.T, username, role string) (models.Users, string) {
+	t.Helper()
+
+	user := s.register(t, username, username+"@example.com", "Secret123!")
+	s.verify(t)
+	if err := models.DB.Model(&user).Update("role", role).Error; err != nil {
+		t.Fatal(err)
+	}
+	token, status := s.login(t, username, "Secret123!")
+	if status != http.StatusOK {
+		t.Fatalf("login %s: status %d", username, status)
+	}
+	return user, token
+}
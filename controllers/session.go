@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"test/logging"
+	"test/middleware"
+	"test/models"
+
+	"go.uber.org/zap"
+)
+
+const loginCSRFCookieName = "login_csrf"
+
+// loginPage is the data rendered into templates/login.html.
+type loginPage struct {
+	CSRF       string
+	Error      string
+	Identifier string
+	MFAToken   string
+	Next       string
+}
+
+func renderLogin(w http.ResponseWriter, r *http.Request, status int, page loginPage) {
+	page.CSRF, _ = loginCSRFCookie(r)
+	renderPage(w, status, "login.html", page)
+}
+
+// The login form has no session yet, so it is protected with a
+// double-submit cookie instead of the session-derived CSRF token.
+func setLoginCSRFCookie(w http.ResponseWriter, r *http.Request, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCSRFCookieName,
+		Value:    token,
+		Path:     "/login",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func loginCSRFCookie(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(loginCSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+func validLoginCSRF(r *http.Request) bool {
+	expected, ok := loginCSRFCookie(r)
+	submitted := r.PostFormValue(middleware.CSRFFieldName)
+	return ok && submitted != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(submitted)) == 1
+}
+
+// safeNext only allows local redirect targets.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return ""
+	}
+	return next
+}
+
+func (h *Handler) redirectAfterLogin(w http.ResponseWriter, r *http.Request, user *models.Users) {
+	token, err := h.startSession(r.Context(), user)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Failed Generate token")
+		renderLogin(w, r, http.StatusInternalServerError, loginPage{Error: "Failed to sign in, please try again"})
+		return
+	}
+
+	middleware.SetSessionCookie(w, r, token, h.sessionTTL)
+
+	target := safeNext(r.PostFormValue("next"))
+	if target == "" {
+		target = "/dashboard"
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+func (h *Handler) LoginForm(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.PostFormValue("next"))
+	if !validLoginCSRF(r) {
+		logging.FromContext(r.Context()).Warn("Invalid login CSRF token")
+		renderLogin(w, r, http.StatusForbidden, loginPage{Error: "Your session expired, please try again", Next: next})
+		return
+	}
+
+	identifier := strings.TrimSpace(r.PostFormValue("loginIdentifier"))
+	user, failure := h.authenticatePassword(r, identifier, r.PostFormValue("password"))
+	if failure != nil {
+		renderLogin(w, r, failure.status, loginPage{Error: failure.message, Identifier: identifier, Next: next})
+		return
+	}
+
+	if user.TOTPEnabled {
+		renderLogin(w, r, http.StatusOK, loginPage{MFAToken: h.issueMFAChallenge(user), Next: next})
+		return
+	}
+
+	h.redirectAfterLogin(w, r, &user)
+}
+
+func (h *Handler) LoginMFAForm(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.PostFormValue("next"))
+	if !validLoginCSRF(r) {
+		logging.FromContext(r.Context()).Warn("Invalid login CSRF token")
+		renderLogin(w, r, http.StatusForbidden, loginPage{Error: "Your session expired, please try again", Next: next})
+		return
+	}
+
+	input := LoginMFAInput{
+		MFAToken:     r.PostFormValue("mfa_token"),
+		Code:         r.PostFormValue("code"),
+		RecoveryCode: r.PostFormValue("recovery_code"),
+	}
+	user, failure := h.authenticateMFA(r, input)
+	if failure != nil {
+		page := loginPage{Error: failure.message, Next: next}
+		if failure.status == http.StatusUnauthorized && failure.message == "Invalid code" {
+			page.MFAToken = input.MFAToken
+		}
+		renderLogin(w, r, failure.status, page)
+		return
+	}
+
+	h.redirectAfterLogin(w, r, &user)
+}
+
+func Logout(w http.ResponseWriter, r *http.Request) {
+	// An impersonating admin only ends their own view; the user's sessions
+	// are left alone.
+	if principal, _ := middleware.PrincipalFromContext(r.Context()); principal.Impersonating() {
+		logging.FromContext(r.Context()).Info("Impersonation ended", zap.Uint("userID", principal.UserID), zap.Uint("adminID", principal.ImpersonatorID))
+		middleware.ClearSessionCookie(w)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Bumping the session version also invalidates copies of the cookie and
+	// any JWT issued before the logout.
+	var user models.Users
+	userID, err := middleware.GetUserIdFromToken(r)
+	if err == nil {
+		err = models.DB.Where("id = ?", userID).First(&user).Error
+	}
+	if err == nil {
+		if err := revokeSessions(models.DB, &user); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to revoke sessions", zap.Uint("userID", userID), zap.Error(err))
+		}
+		logging.FromContext(r.Context()).Info("User Logout", zap.Uint("userID", userID))
+	}
+
+	middleware.ClearSessionCookie(w)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
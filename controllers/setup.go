@@ -2,30 +2,162 @@ package controllers
 
 import (
 	"net/http"
+	"strconv"
+	"test/config"
+	"test/metrics"
 	"test/middleware"
+	"test/models"
+	"test/repository"
+	"test/utils"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
-func New() http.Handler {
+// Deps is everything New needs to build the handlers.
+type Deps struct {
+	Config config.Config
+	repository.Repositories
+}
+
+// Handler serves the routes whose storage goes through repositories: quests,
+// quest completion, inventory, registration, verification and password
+// login. The package-level handlers (admin, API keys, assignments, bulk
+// transfer, categories, tags, MFA, OIDC linking and sessions) and the
+// session check in middleware still use models.DB directly; moving them is
+// left for later, so tests that reach them need a database.
+type Handler struct {
+	quests   repository.QuestRepository
+	users    repository.UserRepository
+	uoms     repository.UomRepository
+	products repository.ProductRepository
+	tx       repository.Transactor
+
+	// baseURL is the public address used in links sent by email.
+	baseURL    string
+	sessionTTL time.Duration
+	signer     *utils.Signer
+}
+
+// pathID parses the {id} route variable. Malformed IDs are reported the same
+// way as missing records.
+func pathID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, repository.ErrNotFound
+	}
+	return uint(id), nil
+}
+
+func New(deps Deps) http.Handler {
+	cfg := deps.Config
+	h := &Handler{
+		quests:     deps.Quests,
+		users:      deps.Users,
+		uoms:       deps.Uoms,
+		products:   deps.Products,
+		tx:         deps.Tx,
+		baseURL:    cfg.Server.BaseURL,
+		sessionTTL: cfg.Auth.SessionTTL,
+		signer:     utils.NewSigner([]byte(cfg.Auth.JWTSecret)),
+	}
+	authenticator := middleware.NewAuthenticator(h.signer)
+
 	router := mux.NewRouter()
+	router.Use(middleware.RecordRoute)
+	router.HandleFunc("/healthz", Healthz).Methods("GET")
+	router.HandleFunc("/readyz", Readyz).Methods("GET")
+	router.HandleFunc("/version", Version).Methods("GET")
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
 	api := router.PathPrefix("/api").Subrouter()
-	api.Use(middleware.AuthMiddleware)
-	api.HandleFunc("/quests", GetAllQuests).Methods("GET")
-	api.HandleFunc("/quest/{id}", GetQuest).Methods("GET")
-	api.HandleFunc("/quest", CreateQuest).Methods("POST")
-	api.HandleFunc("/quest/{id}", UpdateQuest).Methods("PUT")
-	api.HandleFunc("/quest/{id}", DeleteQuest).Methods("DELETE")
-	api.HandleFunc("/get-info", GetInfo).Methods("GET")
-	api.HandleFunc("/quest-complete", QuestComplete).Methods("POST")
-
-	api.HandleFunc("/uom", GetAllUom).Methods("GET")
-	api.HandleFunc("/uom/create", CreateUom).Methods("POST")
+	api.Use(authenticator.AuthMiddleware)
+	middleware.Scope(api.HandleFunc("/quests", h.GetAllQuests).Methods("GET"), models.ScopeQuestsRead)
+	middleware.Scope(api.HandleFunc("/quests/nearby", h.GetNearbyQuests).Methods("GET"), models.ScopeQuestsRead)
+	middleware.Scope(api.HandleFunc("/quest/{id}", h.GetQuest).Methods("GET"), models.ScopeQuestsRead)
+	middleware.Scope(api.HandleFunc("/quest", h.CreateQuest).Methods("POST"), models.ScopeQuestsWrite)
+	middleware.Scope(api.HandleFunc("/quest/{id}", h.UpdateQuest).Methods("PUT"), models.ScopeQuestsWrite)
+	middleware.Scope(api.HandleFunc("/quest/{id}", h.DeleteQuest).Methods("DELETE"), models.ScopeQuestsWrite)
+	middleware.Scope(api.HandleFunc("/quest/{id}/clone", h.CloneQuest).Methods("POST"), models.ScopeQuestsWrite)
+	api.HandleFunc("/quest/{id}/assign", AssignQuest).Methods("POST")
+	middleware.Sensitive(api.HandleFunc("/me/password", h.ChangePassword).Methods("POST"))
+	middleware.Sensitive(api.HandleFunc("/me/2fa/enroll", EnrollTOTP).Methods("POST"))
+	middleware.Sensitive(api.HandleFunc("/me/2fa/confirm", ConfirmTOTP).Methods("POST"))
+	middleware.Sensitive(api.HandleFunc("/me/2fa/disable", DisableTOTP).Methods("POST"))
+	middleware.Scope(api.HandleFunc("/me/quests", GetMyQuests).Methods("GET"), models.ScopeQuestsRead)
+	middleware.Scope(api.HandleFunc("/me/quests/{id}/start", StartMyQuest).Methods("POST"), models.ScopeQuestsComplete)
+	middleware.Scope(api.HandleFunc("/quest-templates", GetAllQuestTemplates).Methods("GET"), models.ScopeQuestsRead)
+	api.HandleFunc("/quest-templates", h.CreateQuestTemplate).Methods("POST")
+	middleware.Scope(api.HandleFunc("/quest-templates/{id}", GetQuestTemplate).Methods("GET"), models.ScopeQuestsRead)
+	middleware.Scope(api.HandleFunc("/quest-templates/{id}/instantiate", h.InstantiateQuestTemplate).Methods("POST"), models.ScopeQuestsWrite)
+	middleware.Scope(api.HandleFunc("/get-info", h.GetInfo).Methods("GET"), models.ScopeProfileRead)
+	middleware.Sensitive(middleware.Scope(api.HandleFunc("/quest-complete", h.QuestComplete).Methods("POST"), models.ScopeQuestsComplete))
+
+	middleware.Scope(api.HandleFunc("/tags", GetTags).Methods("GET"), models.ScopeQuestsRead)
+	middleware.Scope(api.HandleFunc("/tags/autocomplete", AutocompleteTags).Methods("GET"), models.ScopeQuestsRead)
+	middleware.Scope(api.HandleFunc("/categories", GetAllCategories).Methods("GET"), models.ScopeQuestsRead)
+	api.HandleFunc("/categories", CreateCategory).Methods("POST")
+
+	api.HandleFunc("/me/api-keys", GetMyAPIKeys).Methods("GET")
+	middleware.Sensitive(api.HandleFunc("/me/api-keys", CreateAPIKey).Methods("POST"))
+	middleware.Sensitive(api.HandleFunc("/me/api-keys/{id}", RevokeAPIKey).Methods("DELETE"))
+
+	api.HandleFunc("/admin/users/{id}/unlock", UnlockUser).Methods("POST")
+	api.HandleFunc("/admin/users/{id}/impersonate", h.Impersonate).Methods("POST")
+	api.HandleFunc("/admin/service-accounts", CreateServiceAccount).Methods("POST")
+	api.HandleFunc("/admin/service-accounts/{id}/api-keys", CreateServiceAccountKey).Methods("POST")
+	api.HandleFunc("/admin/log-level", GetLogLevel).Methods("GET")
+	api.HandleFunc("/admin/log-level", SetLogLevel).Methods("PUT")
+
+	middleware.Scope(api.HandleFunc("/uom", h.GetAllUom).Methods("GET"), models.ScopeInventoryRead)
+	middleware.Scope(api.HandleFunc("/uom/create", h.CreateUom).Methods("POST"), models.ScopeInventoryWrite)
+
+	for kind, scopes := range bulkScopes {
+		middleware.Scope(api.HandleFunc("/import/{kind:"+kind+"}", ImportRecords).Methods("POST"), scopes.write)
+		middleware.Scope(api.HandleFunc("/export/{kind:"+kind+"}", ExportRecords).Methods("GET"), scopes.read)
+	}
 
 	users := router.PathPrefix("/users").Subrouter()
-	users.HandleFunc("/register", Register).Methods("POST")
-	users.HandleFunc("/login", Login).Methods("POST")
+	users.HandleFunc("/register", h.Register).Methods("POST")
+	users.HandleFunc("/login", h.Login).Methods("POST")
+	users.HandleFunc("/login/mfa", h.LoginMFA).Methods("POST")
+	users.HandleFunc("/verify", h.VerifyEmail).Methods("GET")
+	users.HandleFunc("/verify/resend", h.ResendVerification).Methods("POST")
+	users.HandleFunc("/password/forgot", h.ForgotPassword).Methods("POST")
+	users.HandleFunc("/password/reset", ResetPassword).Methods("POST")
+
+	auth := router.PathPrefix("/auth/oidc").Subrouter()
+	auth.HandleFunc("", GetOIDCProviders).Methods("GET")
+	auth.HandleFunc("/{provider}/login", h.OIDCLogin).Methods("GET")
+	auth.HandleFunc("/{provider}/callback", h.OIDCCallback).Methods("GET")
 
 	router.HandleFunc("/login", LoginHTML).Methods("GET")
-	return router
+	router.HandleFunc("/login", h.LoginForm).Methods("POST")
+	router.HandleFunc("/login/mfa", h.LoginMFAForm).Methods("POST")
+	router.HandleFunc("/password/reset", ResetPasswordHTML).Methods("GET")
+	router.HandleFunc("/password/reset", ResetPasswordForm).Methods("POST")
+
+	site := router.NewRoute().Subrouter()
+	site.Use(authenticator.RequireSession)
+	site.HandleFunc("/dashboard", Dashboard).Methods("GET")
+	middleware.Sensitive(site.HandleFunc("/dashboard/quests/{id}/complete", h.CompleteQuestForm).Methods("POST"))
+	site.HandleFunc("/logout", Logout).Methods("POST")
+
+	admin := site.PathPrefix("/admin").Subrouter()
+	admin.HandleFunc("", AdminHome).Methods("GET")
+	admin.HandleFunc("/users", AdminUsers).Methods("GET")
+	admin.HandleFunc("/users/{id}", AdminUser).Methods("GET")
+	admin.HandleFunc("/users/{id}", AdminUpdateUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/points", AdminAdjustPoints).Methods("POST")
+	admin.HandleFunc("/quests", AdminQuests).Methods("GET")
+	admin.HandleFunc("/quests/{id}/status", AdminSetQuestStatus).Methods("POST")
+	admin.HandleFunc("/inventory", h.AdminInventory).Methods("GET")
+	admin.HandleFunc("/uoms", h.AdminCreateUom).Methods("POST")
+	admin.HandleFunc("/uoms/{id}", h.AdminUpdateUom).Methods("POST")
+	admin.HandleFunc("/uoms/{id}/delete", h.AdminDeleteUom).Methods("POST")
+	admin.HandleFunc("/products", h.AdminCreateProduct).Methods("POST")
+	admin.HandleFunc("/products/{id}", h.AdminUpdateProduct).Methods("POST")
+	admin.HandleFunc("/products/{id}/delete", h.AdminDeleteProduct).Methods("POST")
+	admin.HandleFunc("/audit", AdminAuditLog).Methods("GET")
+	return middleware.Instrument(router)
 }
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"test/logging"
+	"test/models"
+	"test/utils"
+
+	"go.uber.org/zap"
+)
+
+type TagCount struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	QuestCount int64  `json:"quest_count"`
+}
+
+func GetTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var counts []TagCount
+	err := models.DB.Table("tags").
+		Select("tags.id, tags.name, COUNT(quest_tags.quest_id) AS quest_count").
+		Joins("LEFT JOIN quest_tags ON quest_tags.tag_id = tags.id").
+		Group("tags.id, tags.name").
+		Order("quest_count DESC, tags.name").
+		Scan(&counts).Error
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to count tags", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	json.NewEncoder(w).Encode(counts)
+}
+
+func AutocompleteTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	prefix := models.NormalizeTagName(r.URL.Query().Get("q"))
+	limit := 10
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 50 {
+			logging.FromContext(r.Context()).Warn("Invalid autocomplete limit")
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+
+	var tags []models.Tag
+	err := models.DB.Where(`name LIKE ? ESCAPE '\'`, escaped+"%").Order("name").Limit(limit).Find(&tags).Error
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to autocomplete tags", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	json.NewEncoder(w).Encode(tags)
+}
+
+func splitTagNames(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	return models.NormalizeTagNames(strings.Split(raw, ","))
+}
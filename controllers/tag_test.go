@@ -0,0 +1,37 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/url"
+	"test/models"
+	"testing"
+)
+
+func TestAutocompleteTagsMatchesWildcardsLiterally(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp(t, "alice", models.RolePlayer)
+	for _, name := range []string{"half_day", "halfway", "100%", "1000", `back\slash`, "backslash"} {
+		if err := models.DB.Create(&models.Tag{Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"half_", "half_day"},
+		{"100%", "100%"},
+		{`back\`, `back\slash`},
+	}
+	for _, tt := range tests {
+		var tags []models.Tag
+		path := "/api/tags/autocomplete?q=" + url.QueryEscape(tt.prefix)
+		if status := s.do(t, "GET", path, token, nil, &tags); status != http.StatusOK {
+			t.Fatalf("autocomplete %q: status %d", tt.prefix, status)
+		}
+		if len(tags) != 1 || tags[0].Name != tt.want {
+			t.Errorf("autocomplete %q = %v, want only %q", tt.prefix, tags, tt.want)
+		}
+	}
+}
@@ -2,37 +2,44 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"test/logging"
 	"test/middleware"
 	"test/models"
+	"test/repository"
 	"test/utils"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
 // var validate *validator.Validate
 
-func GetAllUom(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetAllUom(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	var uom []models.Uom
-	models.DB.Find(&uom)
+	uoms, err := h.uoms.List(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load uoms", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
 
-	json.NewEncoder(w).Encode(uom)
+	json.NewEncoder(w).Encode(uoms)
 }
 
-func GetUom(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetUom(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	id := mux.Vars(r)["id"]
+	id, err := pathID(r)
 	var uom models.Uom
-
-	if err := models.DB.Where("id = ?", id).First(&uom).Error; err != nil {
-		logging.Warn("Uom not found")
+	if err == nil {
+		uom, err = h.uoms.Get(r.Context(), id)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Uom not found")
 		utils.RespondWithError(w, http.StatusNotFound, "Uom not found")
 		return
 	}
@@ -44,26 +51,26 @@ type UomInput struct {
 	Name string `json:"name" validate:"required"`
 }
 
-func CreateUom(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateUom(w http.ResponseWriter, r *http.Request) {
 	var input UomInput
 
 	userID, err := middleware.GetUserIdFromToken(r)
 	if err != nil {
-		logging.Error("Unauthorized", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Unauthorized", zap.Error(err))
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		logging.Error("Internal Server Error", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Internal Server Error", zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
 	err = json.Unmarshal(body, &input)
 	if err != nil {
-		logging.Error("Invalid request body", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
@@ -71,7 +78,7 @@ func CreateUom(w http.ResponseWriter, r *http.Request) {
 	validate = validator.New()
 	err = validate.Struct(input)
 	if err != nil {
-		logging.Error(err.Error(), zap.Error(err))
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
 		return
 	}
@@ -81,9 +88,9 @@ func CreateUom(w http.ResponseWriter, r *http.Request) {
 		UserID: userID,
 	}
 
-	err = models.DB.Create(uom).Error
+	err = h.uoms.Create(r.Context(), uom)
 	if err != nil {
-		logging.Error(err.Error(), zap.Error(err))
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create uom")
 		return
 	}
@@ -92,14 +99,16 @@ func CreateUom(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(uom)
 }
 
-func UpdateUom(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateUom(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	id := mux.Vars(r)["id"]
+	id, err := pathID(r)
 	var uom models.Uom
-
-	if err := models.DB.Where("id = ?", id).First(&uom).Error; err != nil {
-		logging.Warn("uom not found")
+	if err == nil {
+		uom, err = h.uoms.Get(r.Context(), id)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("uom not found")
 		utils.RespondWithError(w, http.StatusNotFound, "uom not found")
 		return
 	}
@@ -110,35 +119,42 @@ func UpdateUom(w http.ResponseWriter, r *http.Request) {
 	_ = json.Unmarshal(body, &input)
 
 	validate = validator.New()
-	err := validate.Struct(input)
+	err = validate.Struct(input)
 
 	if err != nil {
-		logging.Error(err.Error(), zap.Error(err))
+		logging.FromContext(r.Context()).Error(err.Error(), zap.Error(err))
 		utils.RespondWithError(w, http.StatusBadRequest, "Validation Error")
 		return
 	}
 
 	uom.Name = input.Name
 
-	models.DB.Save(&uom)
+	if err := h.uoms.Update(r.Context(), &uom); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to update uom", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update uom")
+		return
+	}
 
 	json.NewEncoder(w).Encode(uom)
 }
 
-func DeleteUom(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteUom(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	id := mux.Vars(r)["id"]
-	var uom models.Uom
-
-	if err := models.DB.Where("id = ?", id).First(&uom).Error; err != nil {
-		logging.Warn("Uom not fouund")
+	id, err := pathID(r)
+	if err == nil {
+		err = h.uoms.Delete(r.Context(), id)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		logging.FromContext(r.Context()).Warn("Uom not fouund")
 		utils.RespondWithError(w, http.StatusNotFound, "Uom not found")
 		return
 	}
-
-	models.DB.Delete(&uom)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete uom", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete uom")
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
-	json.NewEncoder(w).Encode(uom)
 }
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"test/logging"
+	"test/mail"
+	"test/models"
+	"test/utils"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	verificationPurpose  = "verify-email"
+	verificationTTL      = 24 * time.Hour
+	verificationCooldown = time.Minute
+)
+
+func (h *Handler) sendVerificationEmail(ctx context.Context, user *models.Users) error {
+	value := strconv.FormatUint(uint64(user.ID), 10) + ":" + user.Email
+	token := h.signer.Sign(verificationPurpose, value, time.Now().Add(verificationTTL))
+	link := h.baseURL + "/users/verify?token=" + url.QueryEscape(token)
+
+	err := mail.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.Username, link, verificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.VerificationSentAt = &now
+	return h.users.SetVerificationSentAt(ctx, user.ID, now)
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	value, err := h.signer.Verify(verificationPurpose, r.URL.Query().Get("token"), time.Now())
+	if errors.Is(err, utils.ErrSignatureExpired) {
+		logging.FromContext(r.Context()).Warn("Expired verification link")
+		utils.RespondWithError(w, http.StatusGone, "Verification link has expired")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Invalid verification link")
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid verification link")
+		return
+	}
+
+	rawID, email, _ := strings.Cut(value, ":")
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	var user models.Users
+	if err == nil {
+		user, err = h.users.Get(r.Context(), uint(id))
+	}
+	if err != nil || user.Email != email {
+		logging.FromContext(r.Context()).Warn("Verification link does not match a user")
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid verification link")
+		return
+	}
+
+	if !user.IsVerified() {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := h.users.SetEmailVerifiedAt(r.Context(), user.ID, now); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to verify user", zap.Error(err))
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify email")
+			return
+		}
+		logging.FromContext(r.Context()).Info("Email verified", zap.Uint("userID", user.ID))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email verified"})
+}
+
+type ResendVerificationInput struct {
+	Email string `json:"email" validate:"required"`
+}
+
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var input ResendVerificationInput
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to read request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read request body")
+		return
+	}
+
+	if err := json.Unmarshal(body, &input); err != nil || input.Email == "" {
+		logging.FromContext(r.Context()).Error("Invalid request body", zap.Error(err))
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// The response is identical whether or not the address is registered so
+	// the endpoint cannot be used to discover accounts.
+	accepted := func() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"message": "If the account exists and is unverified, a new link has been sent"})
+	}
+
+	user, err := h.users.FindByEmail(r.Context(), input.Email)
+	if err != nil || user.IsVerified() {
+		accepted()
+		return
+	}
+
+	// Requests during the cooldown are dropped silently; a 429 would only be
+	// sent for addresses that have an account.
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < verificationCooldown {
+		logging.FromContext(r.Context()).Info("Verification email throttled", zap.Uint("userID", user.ID))
+		accepted()
+		return
+	}
+
+	if err := h.sendVerificationEmail(r.Context(), &user); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to send verification email", zap.Uint("userID", user.ID), zap.Error(err))
+	}
+
+	accepted()
+}
+
+func requireVerified(w http.ResponseWriter, user models.Users) bool {
+	if user.IsVerified() {
+		return true
+	}
+	logging.Warn("Unverified user blocked", zap.Uint("userID", user.ID))
+	utils.RespondWithError(w, http.StatusForbidden, "Email address has not been verified")
+	return false
+}
@@ -0,0 +1,92 @@
+// Package health keeps the readiness checks behind /readyz. Code that adds
+// a dependency the server cannot work without registers a check for it.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+var (
+	mu     sync.Mutex
+	checks = map[string]CheckFunc{}
+)
+
+// Register adds or replaces the check called name.
+func Register(name string, check CheckFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	checks[name] = check
+}
+
+// Result is one check's outcome. Only Name and OK are served to callers;
+// Error and Duration may reveal details of the infrastructure and are meant
+// for the server's logs.
+type Result struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Error    string        `json:"-"`
+	Duration time.Duration `json:"-"`
+}
+
+// Run runs every registered check concurrently, each limited to timeout, and
+// returns the results by name and whether all of them passed.
+func Run(ctx context.Context, timeout time.Duration) ([]Result, bool) {
+	mu.Lock()
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	registered := make([]CheckFunc, len(names))
+	for i, name := range names {
+		registered[i] = checks[name]
+	}
+	mu.Unlock()
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = run(ctx, names[i], registered[i], timeout)
+		}(i)
+	}
+	wg.Wait()
+
+	healthy := true
+	for _, result := range results {
+		healthy = healthy && result.OK
+	}
+	return results, healthy
+}
+
+func run(ctx context.Context, name string, check CheckFunc, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- check(ctx)
+	}()
+
+	// A check that ignores its context still cannot hold up the probe.
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Name: name, OK: err == nil, Duration: time.Since(start)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+// Package lifecycle runs the HTTP server and background workers and shuts
+// them down in order when the process is asked to stop.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"test/logging"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager collects shutdown hooks and runs them in reverse order of
+// registration, so resources opened first, like the database, are closed
+// last.
+type Manager struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	hooks   []hook
+	workers map[string]chan struct{}
+}
+
+// New returns a Manager that gives shutdown at most timeout to complete.
+func New(timeout time.Duration) *Manager {
+	return &Manager{timeout: timeout, workers: map[string]chan struct{}{}}
+}
+
+func (m *Manager) OnShutdown(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Go starts a background worker. Its context is cancelled on shutdown and
+// shutdown waits for it to return.
+func (m *Manager) Go(name string, worker func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.mu.Lock()
+	m.workers[name] = done
+	m.mu.Unlock()
+	go func() {
+		defer close(done)
+		worker(ctx)
+	}()
+
+	m.OnShutdown(name, func(stopCtx context.Context) error {
+		cancel()
+		// A worker that has already returned counts as stopped even if an
+		// earlier step used up the timeout.
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	})
+}
+
+// CheckWorkers reports an error naming every worker that has returned, for
+// use as a readiness check.
+func (m *Manager) CheckWorkers(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stopped []string
+	for name, done := range m.workers {
+		select {
+		case <-done:
+			stopped = append(stopped, name)
+		default:
+		}
+	}
+	if len(stopped) > 0 {
+		sort.Strings(stopped)
+		return fmt.Errorf("workers not running: %s", strings.Join(stopped, ", "))
+	}
+	return nil
+}
+
+// Serve serves HTTP on listener until ctx is cancelled or the server fails,
+// then shuts everything down. In-flight requests are drained before the
+// workers and other resources are stopped.
+func (m *Manager) Serve(ctx context.Context, server *http.Server, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	m.OnShutdown("http server", func(stopCtx context.Context) error {
+		if err := server.Shutdown(stopCtx); err != nil {
+			server.Close()
+			return err
+		}
+		return nil
+	})
+
+	logging.Info("Server started", zap.String("addr", listener.Addr().String()))
+
+	var err error
+	select {
+	case <-ctx.Done():
+		logging.Info("Shutting down", zap.Duration("timeout", m.timeout))
+	case err = <-serveErr:
+		logging.Error("Server stopped unexpectedly", zap.Error(err))
+	}
+
+	return errors.Join(err, m.Shutdown())
+}
+
+// Shutdown runs every hook, newest first, within the manager's timeout and
+// returns the combined errors.
+func (m *Manager) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.hooks = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].stop(ctx); err != nil {
+			logging.Error("Shutdown step failed", zap.String("step", hooks[i].name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", hooks[i].name, err))
+			continue
+		}
+		logging.Info("Stopped", zap.String("step", hooks[i].name))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gormReserveRetries bounds how often Reserve retries after losing a race
+// for the same key.
+const gormReserveRetries = 10
+
+// ErrContention is returned when a counter keeps changing under Reserve.
+var ErrContention = errors.New("lockout: too many concurrent attempts")
+
+type LoginAttempt struct {
+	Key         string `gorm:"primaryKey;size:255"`
+	Failures    int
+	LastFailure time.Time
+}
+
+// GormStore keeps counters in the login_attempts table so they are shared
+// by every instance of the service.
+type GormStore struct {
+	db *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Reserve reads the counter, decides, and writes the new value only if the
+// counter still holds what was read; a concurrent change makes it start over.
+func (s *GormStore) Reserve(ctx context.Context, key string, now time.Time, policy Policy) (time.Duration, error) {
+	db := s.db.WithContext(ctx)
+
+	for i := 0; i < gormReserveRetries; i++ {
+		var attempt LoginAttempt
+		found := db.Where("key = ?", key).Limit(1).Find(&attempt)
+		if found.Error != nil {
+			return 0, found.Error
+		}
+
+		entry := Entry{Failures: attempt.Failures, LastFailure: attempt.LastFailure}
+		if wait := policy.retryAfter(entry, now); wait > 0 {
+			return wait, nil
+		}
+		failures := attempt.Failures + 1
+		if now.Sub(attempt.LastFailure) > policy.Window {
+			failures = 1
+		}
+
+		var claimed *gorm.DB
+		if found.RowsAffected == 0 {
+			claimed = db.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&LoginAttempt{Key: key, Failures: 1, LastFailure: now})
+		} else {
+			claimed = db.Model(&LoginAttempt{}).
+				Where("key = ? AND failures = ?", key, attempt.Failures).
+				Updates(map[string]interface{}{"failures": failures, "last_failure": now})
+		}
+		if claimed.Error != nil {
+			return 0, claimed.Error
+		}
+		if claimed.RowsAffected == 1 {
+			return 0, nil
+		}
+	}
+	return 0, ErrContention
+}
+
+func (s *GormStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Model(&LoginAttempt{}).
+		Where("key = ? AND failures > 0", key).
+		Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+func (s *GormStore) Reset(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&LoginAttempt{}).Error
+}
@@ -0,0 +1,114 @@
+package lockout
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+type Entry struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps attempt counters. Reserve must be atomic: concurrent calls for
+// one key never let through more attempts than the policy allows.
+type Store interface {
+	// Reserve counts an attempt for key unless policy requires a wait first,
+	// in which case it returns the wait and leaves the counter alone. A
+	// counter whose last attempt is older than the policy window restarts.
+	Reserve(ctx context.Context, key string, now time.Time, policy Policy) (time.Duration, error)
+	// Release takes back one reserved attempt.
+	Release(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy allows FreeAttempts failures without delay, then doubles the
+// required wait from BaseDelay on every further failure up to MaxDelay.
+type Policy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	Window       time.Duration
+}
+
+func (p Policy) Delay(entry Entry) time.Duration {
+	over := entry.Failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	delay := time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(over-1)))
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+func (p Policy) retryAfter(entry Entry, now time.Time) time.Duration {
+	if entry.Failures == 0 || now.Sub(entry.LastFailure) > p.Window {
+		return 0
+	}
+	// Concurrent attempts may have recorded a failure slightly after now, so
+	// free attempts are checked explicitly rather than by their zero delay.
+	delay := p.Delay(entry)
+	if delay == 0 {
+		return 0
+	}
+	if wait := entry.LastFailure.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+var (
+	DefaultAccountPolicy = Policy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 15 * time.Minute, Window: time.Hour}
+	DefaultIPPolicy      = Policy{FreeAttempts: 20, BaseDelay: time.Second, MaxDelay: 15 * time.Minute, Window: time.Hour}
+)
+
+type Guard struct {
+	store   Store
+	account Policy
+	ip      Policy
+}
+
+func NewGuard(store Store) *Guard {
+	return &Guard{store: store, account: DefaultAccountPolicy, ip: DefaultIPPolicy}
+}
+
+func (g *Guard) WithPolicies(account, ip Policy) *Guard {
+	g.account, g.ip = account, ip
+	return g
+}
+
+func AccountKey(id string) string { return "account:" + id }
+func IPKey(ip string) string      { return "ip:" + ip }
+
+// Attempt reserves an attempt for the account and the address before the
+// credentials are checked, so parallel guesses cannot all slip in under the
+// limit. Reserved attempts count as failures until Succeed is called. When
+// either is throttled, Attempt returns the wait instead.
+func (g *Guard) Attempt(ctx context.Context, account, ip string, now time.Time) (time.Duration, error) {
+	wait, err := g.store.Reserve(ctx, AccountKey(account), now, g.account)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	return g.store.Reserve(ctx, IPKey(ip), now, g.ip)
+}
+
+// Succeed clears the account counter and takes back the attempt reserved
+// for the address. The rest of the address counter is left alone so an
+// attacker cannot reset it by logging into an account they own.
+func (g *Guard) Succeed(ctx context.Context, account, ip string) error {
+	if err := g.store.Reset(ctx, AccountKey(account)); err != nil {
+		return err
+	}
+	return g.store.Release(ctx, IPKey(ip))
+}
+
+func (g *Guard) UnlockAccount(ctx context.Context, account string) error {
+	return g.store.Reset(ctx, AccountKey(account))
+}
+
+func (g *Guard) UnlockIP(ctx context.Context, ip string) error {
+	return g.store.Reset(ctx, IPKey(ip))
+}
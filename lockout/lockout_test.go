@@ -0,0 +1,52 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGuardSucceedKeepsAddressCounter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	guard := NewGuard(NewMemoryStore()).WithPolicies(testPolicy, testPolicy)
+
+	// Two failures against someone else's account from the address.
+	for i := 0; i < 2; i++ {
+		if wait, err := guard.Attempt(ctx, "victim", "1.2.3.4", now); err != nil || wait != 0 {
+			t.Fatalf("attempt %d: wait %s, err %v", i+1, wait, err)
+		}
+	}
+	// Successful logins into an own account do not clear them.
+	for i := 0; i < 5; i++ {
+		if wait, err := guard.Attempt(ctx, "own", "1.2.3.4", now); err != nil || wait != 0 {
+			t.Fatalf("own login %d: wait %s, err %v", i+1, wait, err)
+		}
+		if err := guard.Succeed(ctx, "own", "1.2.3.4"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if wait, _ := guard.Attempt(ctx, "third", "1.2.3.4", now); wait != 0 {
+		t.Fatalf("third failure: wait %s, want none", wait)
+	}
+	if wait, _ := guard.Attempt(ctx, "fourth", "1.2.3.4", now); wait != testPolicy.BaseDelay {
+		t.Fatalf("address wait %s, want %s", wait, testPolicy.BaseDelay)
+	}
+}
+
+type failingStore struct{ *MemoryStore }
+
+var errStoreDown = errors.New("store down")
+
+func (failingStore) Reserve(context.Context, string, time.Time, Policy) (time.Duration, error) {
+	return 0, errStoreDown
+}
+
+func TestGuardAttemptReportsStoreErrors(t *testing.T) {
+	guard := NewGuard(failingStore{NewMemoryStore()})
+	if _, err := guard.Attempt(context.Background(), "a", "1.2.3.4", time.Now()); !errors.Is(err, errStoreDown) {
+		t.Fatalf("err %v, want %v", err, errStoreDown)
+	}
+}
@@ -0,0 +1,73 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const memoryPruneEvery = 1024
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	writes  int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]Entry{}}
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, key string, now time.Time, policy Policy) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	if wait := policy.retryAfter(entry, now); wait > 0 {
+		return wait, nil
+	}
+	if now.Sub(entry.LastFailure) > policy.Window {
+		entry.Failures = 0
+	}
+	entry.Failures++
+	entry.LastFailure = now
+	s.entries[key] = entry
+
+	s.writes++
+	if s.writes%memoryPruneEvery == 0 {
+		s.prune(now, policy.Window)
+	}
+	return 0, nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	entry.Failures--
+	if entry.Failures <= 0 {
+		delete(s.entries, key)
+		return nil
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) prune(now time.Time, window time.Duration) {
+	for key, entry := range s.entries {
+		if now.Sub(entry.LastFailure) > window {
+			delete(s.entries, key)
+		}
+	}
+}
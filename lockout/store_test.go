@@ -0,0 +1,174 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testPolicy = Policy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+
+func newTestGormStore(t *testing.T) Store {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to ":memory:" would open its own empty database.
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&LoginAttempt{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewGormStore(db)
+}
+
+// forEachStore runs test against every Store implementation.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store { return NewMemoryStore() },
+		"gorm":   newTestGormStore,
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			test(t, newStore(t))
+		})
+	}
+}
+
+func reserve(t *testing.T, store Store, key string, now time.Time) time.Duration {
+	t.Helper()
+
+	wait, err := store.Reserve(context.Background(), key, now, testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wait
+}
+
+func TestStoreReserveBacksOff(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		now := time.Now()
+
+		for i := 0; i < testPolicy.FreeAttempts+1; i++ {
+			if wait := reserve(t, store, "k", now); wait != 0 {
+				t.Fatalf("attempt %d: wait %s, want none", i+1, wait)
+			}
+		}
+		if wait := reserve(t, store, "k", now); wait != testPolicy.BaseDelay {
+			t.Fatalf("wait %s, want %s", wait, testPolicy.BaseDelay)
+		}
+		// A refused attempt is not counted, so the wait does not grow.
+		if wait := reserve(t, store, "k", now.Add(time.Second)); wait != testPolicy.BaseDelay-time.Second {
+			t.Fatalf("wait after refusal %s, want %s", wait, testPolicy.BaseDelay-time.Second)
+		}
+
+		now = now.Add(testPolicy.BaseDelay)
+		if wait := reserve(t, store, "k", now); wait != 0 {
+			t.Fatalf("wait after delay %s, want none", wait)
+		}
+		if wait := reserve(t, store, "k", now); wait != 2*testPolicy.BaseDelay {
+			t.Fatalf("wait %s, want %s", wait, 2*testPolicy.BaseDelay)
+		}
+		// Free attempts stamped slightly later by a concurrent request must
+		// not turn into a wait.
+		if wait := reserve(t, store, "skew", now.Add(time.Millisecond)); wait != 0 {
+			t.Fatalf("skew: wait %s, want none", wait)
+		}
+		if wait := reserve(t, store, "skew", now); wait != 0 {
+			t.Fatalf("skew: wait %s, want none", wait)
+		}
+		if wait := reserve(t, store, "other", now); wait != 0 {
+			t.Fatalf("other key: wait %s, want none", wait)
+		}
+	})
+}
+
+func TestStoreReserveIsAtomic(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		now := time.Now()
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+		)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wait, err := store.Reserve(context.Background(), "k", now, testPolicy)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if wait == 0 {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if want := testPolicy.FreeAttempts + 1; allowed != want {
+			t.Fatalf("%d parallel attempts allowed, want %d", allowed, want)
+		}
+	})
+}
+
+func TestStoreWindowRestartsCounter(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		now := time.Now()
+		for i := 0; i < testPolicy.FreeAttempts+1; i++ {
+			reserve(t, store, "k", now)
+		}
+
+		later := now.Add(testPolicy.Window + time.Second)
+		for i := 0; i < testPolicy.FreeAttempts+1; i++ {
+			if wait := reserve(t, store, "k", later); wait != 0 {
+				t.Fatalf("attempt %d after window: wait %s, want none", i+1, wait)
+			}
+		}
+	})
+}
+
+func TestStoreReleaseAndReset(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		now := time.Now()
+
+		for i := 0; i < testPolicy.FreeAttempts+1; i++ {
+			reserve(t, store, "k", now)
+		}
+		if err := store.Release(ctx, "k"); err != nil {
+			t.Fatal(err)
+		}
+		if wait := reserve(t, store, "k", now); wait != 0 {
+			t.Fatalf("wait after release %s, want none", wait)
+		}
+
+		if err := store.Reset(ctx, "k"); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Release(ctx, "k"); err != nil {
+			t.Fatalf("release without counter: %v", err)
+		}
+		for i := 0; i < testPolicy.FreeAttempts+1; i++ {
+			if wait := reserve(t, store, "k", now); wait != 0 {
+				t.Fatalf("attempt %d after reset: wait %s, want none", i+1, wait)
+			}
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"test/config"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+var (
+	// closeOutput closes the current log file, if logs go to one.
+	closeOutput func() error
+	redact      *redactor
+)
+
+// Configure replaces the logger with one built from cfg. Call it at startup,
+// before anything logs concurrently.
+func Configure(cfg config.LoggingConfig) error {
+	var minLevel zapcore.Level
+	if err := minLevel.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return fmt.Errorf("log level: %w", err)
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	var encoder zapcore.Encoder
+	if cfg.Format == "console" {
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	} else {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	}
+
+	var output zapcore.WriteSyncer
+	var closer func() error
+	switch cfg.Output {
+	case "stdout":
+		output = zapcore.Lock(os.Stdout)
+	case "stderr":
+		output = zapcore.Lock(os.Stderr)
+	default:
+		// lumberjack opens the file on the first write; open it now so a bad
+		// path fails at startup rather than on every entry.
+		if err := os.MkdirAll(filepath.Dir(cfg.Output), 0o755); err != nil {
+			return fmt.Errorf("log file: %w", err)
+		}
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("log file: %w", err)
+		}
+		file.Close()
+
+		rotated := &lumberjack.Logger{
+			Filename:   cfg.Output,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAgeDays,
+		}
+		output = zapcore.AddSync(rotated)
+		closer = rotated.Close
+	}
+
+	// The sampler has to wrap redaction, not the other way round: it decides
+	// in Check, which redactCore does not pass on.
+	masks := newRedactor(cfg.RedactFields, cfg.RedactAllow)
+	var core zapcore.Core = redactCore{Core: zapcore.NewCore(encoder, output, level), redactor: masks}
+	if cfg.SampleInitial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.SampleInitial, cfg.SampleThereafter)
+	}
+
+	previous := closeOutput
+	level.SetLevel(minLevel)
+	setLogger(zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel)))
+	closeOutput = closer
+	redact = masks
+	if previous != nil {
+		return previous()
+	}
+	return nil
+}
+
+// Close closes the log file, if logs go to one. Entries are not buffered, so
+// there is nothing else to flush.
+func Close() error {
+	if closeOutput == nil {
+		return nil
+	}
+	return closeOutput()
+}
+
+// Level returns the minimum level that is logged.
+func Level() zapcore.Level {
+	return level.Level()
+}
+
+// SetLevel changes the minimum level of every logger, including request
+// loggers already in use.
+func SetLevel(l zapcore.Level) {
+	level.SetLevel(l)
+}
+
+// Redirect makes the logger write to the core built by newCore instead of
+// the configured output, keeping redaction and the shared level, which is
+// passed to newCore. It is meant for tests, see logtest.Observe. The returned
+// function restores the previous logger.
+func Redirect(newCore func(zapcore.LevelEnabler) zapcore.Core) (restore func()) {
+	previous := logger
+	setLogger(zap.New(redactCore{Core: newCore(level), redactor: redact}))
+	return func() {
+		setLogger(previous)
+	}
+}
@@ -0,0 +1,175 @@
+package logging_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"test/config"
+	"test/logging"
+	"test/logging/logtest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// configure applies cfg and restores the default logger when t ends.
+func configure(t *testing.T, cfg config.LoggingConfig) {
+	t.Helper()
+
+	if err := logging.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := logging.Configure(config.Default().Logging); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// fileConfig logs to a file in a fresh directory, without sampling so every
+// entry is written.
+func fileConfig(t *testing.T) config.LoggingConfig {
+	cfg := config.Default().Logging
+	cfg.Output = filepath.Join(t.TempDir(), "logs", "app.log")
+	cfg.SampleInitial = 0
+	return cfg
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func TestConfigureLevel(t *testing.T) {
+	cfg := fileConfig(t)
+	cfg.Level = "warn"
+	configure(t, cfg)
+
+	logging.Info("hidden")
+	logging.Warn("shown")
+
+	lines := readLines(t, cfg.Output)
+	if len(lines) != 1 || !strings.Contains(lines[0], "shown") {
+		t.Errorf("logged %q, want only the warning", lines)
+	}
+	if got := logging.Level(); got != zapcore.WarnLevel {
+		t.Errorf("Level() = %v, want warn", got)
+	}
+}
+
+func TestSetLevelAppliesToExistingLoggers(t *testing.T) {
+	configure(t, config.Default().Logging)
+	logs := logtest.Observe(t)
+	requestLogger := logging.Logger().With(zap.String("requestID", "r1"))
+
+	requestLogger.Debug("before")
+	logging.SetLevel(zapcore.DebugLevel)
+	requestLogger.Debug("after")
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Message != "after" {
+		t.Errorf("logged %v, want only the entry after SetLevel", entries)
+	}
+}
+
+func TestConfigureFormat(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		cfg := fileConfig(t)
+		configure(t, cfg)
+
+		logging.Info("hello", zap.Int("answer", 42))
+
+		lines := readLines(t, cfg.Output)
+		if len(lines) != 1 {
+			t.Fatalf("logged %d lines, want 1", len(lines))
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+			t.Fatalf("entry is not JSON: %v", err)
+		}
+		if entry["msg"] != "hello" || entry["level"] != "info" || entry["answer"] != float64(42) {
+			t.Errorf("entry = %v", entry)
+		}
+		if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "logging/configure_test.go") {
+			t.Errorf("caller = %q, want this file", caller)
+		}
+	})
+
+	t.Run("console", func(t *testing.T) {
+		cfg := fileConfig(t)
+		cfg.Format = "console"
+		configure(t, cfg)
+
+		logging.Info("hello", zap.Int("answer", 42))
+
+		lines := readLines(t, cfg.Output)
+		if len(lines) != 1 {
+			t.Fatalf("logged %d lines, want 1", len(lines))
+		}
+		columns := strings.Split(lines[0], "\t")
+		if len(columns) < 5 || columns[1] != "INFO" || columns[3] != "hello" || columns[4] != `{"answer": 42}` {
+			t.Errorf("entry = %q", lines[0])
+		}
+	})
+}
+
+func TestConfigureRotatesFile(t *testing.T) {
+	cfg := fileConfig(t)
+	cfg.MaxSizeMB = 1
+	configure(t, cfg)
+
+	padding := strings.Repeat("x", 1024)
+	for i := 0; i < 1200; i++ {
+		logging.Info("filler", zap.Int("i", i), zap.String("padding", padding))
+	}
+
+	files, err := os.ReadDir(filepath.Dir(cfg.Output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("log directory has %d files, want the log and one backup", len(files))
+	}
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 1<<20 {
+			t.Errorf("%s is %d bytes, over the 1 MB limit", file.Name(), info.Size())
+		}
+	}
+}
+
+func TestConfigureRejectsInvalidSettings(t *testing.T) {
+	cfg := config.Default().Logging
+	cfg.Level = "loud"
+	if err := logging.Configure(cfg); err == nil {
+		t.Error("Configure accepted an unknown level")
+	}
+
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg = config.Default().Logging
+	cfg.Output = filepath.Join(blocker, "app.log")
+	if err := logging.Configure(cfg); err == nil {
+		t.Error("Configure accepted a log file that cannot be created")
+	}
+}
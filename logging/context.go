@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type contextKey struct{}
+
+// requestLogger is shared by everything handling one request, so middleware
+// further down the chain can add fields, like the user, that later log lines
+// then carry.
+type requestLogger struct {
+	mu     sync.Mutex
+	logger *zap.Logger
+}
+
+// Logger returns the process-wide logger.
+func Logger() *zap.Logger {
+	return logger
+}
+
+// NewContext returns a copy of ctx that carries l for FromContext.
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestLogger{logger: l})
+}
+
+// FromContext returns the logger stored in ctx, or the process-wide logger if
+// there is none.
+func FromContext(ctx context.Context) *zap.Logger {
+	if scoped, ok := ctx.Value(contextKey{}).(*requestLogger); ok {
+		scoped.mu.Lock()
+		defer scoped.mu.Unlock()
+		return scoped.logger
+	}
+	return logger
+}
+
+// AddFields adds fields to the logger stored in ctx. It does nothing if ctx
+// has no logger.
+func AddFields(ctx context.Context, fields ...zap.Field) {
+	if scoped, ok := ctx.Value(contextKey{}).(*requestLogger); ok {
+		scoped.mu.Lock()
+		defer scoped.mu.Unlock()
+		scoped.logger = scoped.logger.With(fields...)
+	}
+}
@@ -1,38 +1,52 @@
 package logging
 
 import (
+	"test/config"
+
 	"go.uber.org/zap"
 )
 
-var logger *zap.Logger
+var (
+	// level is shared by every logger built here, so changing it applies to
+	// loggers already handed out, such as request loggers.
+	level = zap.NewAtomicLevelAt(zap.InfoLevel)
+
+	logger *zap.Logger
+	// wrapped backs the functions below and skips their frame so entries
+	// report the real caller.
+	wrapped *zap.Logger
+)
 
 func init() {
 	// Initialize logger
-	var err error
-	logger, err = zap.NewProduction()
-	if err != nil {
+	if err := Configure(config.Default().Logging); err != nil {
 		panic("failed to initialize logger: " + err.Error())
 	}
 }
 
+func setLogger(l *zap.Logger) {
+	logger = l
+	wrapped = l.WithOptions(zap.AddCallerSkip(1))
+}
+
 //Notes . . . can accept zero or more arguments
 
 func Debug(msg string, fields ...zap.Field) {
-	logger.Debug(msg, fields...)
+	wrapped.Debug(msg, fields...)
 }
 
 func Info(msg string, fields ...zap.Field) {
-	logger.Info(msg, fields...)
+	wrapped.Info(msg, fields...)
 }
 
 func Warn(msg string, fields ...zap.Field) {
-	logger.Warn(msg, fields...)
+	wrapped.Warn(msg, fields...)
 }
 
 func Error(msg string, fields ...zap.Field) {
-	logger.Error(msg, fields...)
+	wrapped.Error(msg, fields...)
 }
 
 func Fatal(msg string, fields ...zap.Field) {
-	logger.Fatal(msg, fields...)
+	wrapped.Fatal(msg, fields...)
 }
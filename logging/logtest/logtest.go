@@ -0,0 +1,24 @@
+// Package logtest captures log entries for tests.
+package logtest
+
+import (
+	"test/logging"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Observe makes the logger keep entries in memory instead of writing them
+// until t ends, so the test can inspect what was logged. Entries are
+// redacted as they would be in the real output.
+func Observe(t testing.TB) *observer.ObservedLogs {
+	var logs *observer.ObservedLogs
+	restore := logging.Redirect(func(level zapcore.LevelEnabler) zapcore.Core {
+		var core zapcore.Core
+		core, logs = observer.New(level)
+		return core
+	})
+	t.Cleanup(restore)
+	return logs
+}
@@ -0,0 +1,267 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces masked values in log output.
+const Redacted = "[REDACTED]"
+
+// valuePatterns find secrets and PII inside otherwise harmless strings, such
+// as messages and errors. Their names can be put on the allow-list like field
+// names.
+var valuePatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"jwt", regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+	{"bearer", regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/-]+=*`)},
+	{"api_key", regexp.MustCompile(`qk_[A-Za-z0-9-]+_[A-Za-z0-9_-]{16,}`)},
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+}
+
+// redactor masks fields whose name ends in one of fields, e.g. "token" also
+// covers "mfa_token", and values matching patterns.
+type redactor struct {
+	fields   []string
+	patterns []*regexp.Regexp
+	allow    map[string]bool
+}
+
+func newRedactor(fields, allow []string) *redactor {
+	allowed := map[string]bool{}
+	for _, name := range allow {
+		allowed[normalizeKey(name)] = true
+	}
+
+	r := &redactor{allow: allowed}
+	for _, name := range fields {
+		if name = normalizeKey(name); name != "" && !allowed[name] {
+			r.fields = append(r.fields, name)
+		}
+	}
+	for _, p := range valuePatterns {
+		if !allowed[normalizeKey(p.name)] {
+			r.patterns = append(r.patterns, p.pattern)
+		}
+	}
+	return r
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", ".", "").Replace(key))
+}
+
+func (r *redactor) sensitiveKey(key string) bool {
+	key = normalizeKey(key)
+	if r.allow[key] {
+		return false
+	}
+	for _, name := range r.fields {
+		if strings.HasSuffix(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *redactor) value(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// field returns f with its value masked, and whether anything changed.
+// Objects, arrays and reflected values, such as those passed to zap.Any, are
+// encoded and masked key by key.
+func (r *redactor) field(f zapcore.Field) (zapcore.Field, bool) {
+	if r.sensitiveKey(f.Key) {
+		return zap.String(f.Key, Redacted), true
+	}
+
+	var s string
+	switch f.Type {
+	case zapcore.StringType:
+		s = f.String
+	case zapcore.ErrorType:
+		s = f.Interface.(error).Error()
+	case zapcore.StringerType:
+		var ok bool
+		if s, ok = stringOf(f.Interface.(fmt.Stringer)); !ok {
+			return f, false
+		}
+	case zapcore.ByteStringType:
+		s = string(f.Interface.([]byte))
+	case zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		if err := f.Interface.(zapcore.ObjectMarshaler).MarshalLogObject(enc); err != nil {
+			return f, false
+		}
+		masked, changed := r.walk(enc.Fields)
+		if !changed {
+			return f, false
+		}
+		if f.Type == zapcore.InlineMarshalerType {
+			return zap.Inline(maskedObject(masked.(map[string]interface{}))), true
+		}
+		return zap.Object(f.Key, maskedObject(masked.(map[string]interface{}))), true
+	case zapcore.ArrayMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		if err := enc.AddArray(f.Key, f.Interface.(zapcore.ArrayMarshaler)); err != nil {
+			return f, false
+		}
+		masked, changed := r.walk(enc.Fields[f.Key])
+		if !changed {
+			return f, false
+		}
+		return zap.Any(f.Key, masked), true
+	case zapcore.ReflectType:
+		masked, changed := r.walk(f.Interface)
+		if !changed {
+			return f, false
+		}
+		return zap.Any(f.Key, masked), true
+	default:
+		return f, false
+	}
+	masked := r.value(s)
+	if masked == s {
+		return f, false
+	}
+	return zap.String(f.Key, masked), true
+}
+
+// stringOf calls String, reporting false if it panics, as it does on some nil
+// pointers; zap encodes the panic itself.
+func stringOf(stringer fmt.Stringer) (s string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return stringer.String(), true
+}
+
+// walk masks sensitive keys and values in v. Values other than maps, slices
+// and scalars are first turned into those through their JSON encoding, which
+// is also how zap encodes them.
+func (r *redactor) walk(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case nil, bool, json.Number, float64, float32, int, int64, int32, int16, int8,
+		uint, uint64, uint32, uint16, uint8, uintptr, complex128, complex64, time.Time, time.Duration:
+		return v, false
+	case string:
+		masked := r.value(v)
+		return masked, masked != v
+	case map[string]interface{}:
+		var masked map[string]interface{}
+		for key, value := range v {
+			var replacement interface{} = Redacted
+			changed := true
+			if !r.sensitiveKey(key) {
+				replacement, changed = r.walk(value)
+			}
+			if changed {
+				if masked == nil {
+					masked = make(map[string]interface{}, len(v))
+					for k, original := range v {
+						masked[k] = original
+					}
+				}
+				masked[key] = replacement
+			}
+		}
+		if masked == nil {
+			return v, false
+		}
+		return masked, true
+	case []interface{}:
+		var masked []interface{}
+		for i, value := range v {
+			if replacement, changed := r.walk(value); changed {
+				if masked == nil {
+					masked = append([]interface{}(nil), v...)
+				}
+				masked[i] = replacement
+			}
+		}
+		if masked == nil {
+			return v, false
+		}
+		return masked, true
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return v, false
+		}
+		decoder := json.NewDecoder(bytes.NewReader(encoded))
+		decoder.UseNumber()
+		var generic interface{}
+		if err := decoder.Decode(&generic); err != nil {
+			return v, false
+		}
+		if masked, changed := r.walk(generic); changed {
+			return masked, true
+		}
+		return v, false
+	}
+}
+
+// maskedObject is a masked copy of a zapcore.ObjectMarshaler's fields.
+type maskedObject map[string]interface{}
+
+func (o maskedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for key, value := range o {
+		if err := enc.AddReflected(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *redactor) fieldsOf(fields []zapcore.Field) []zapcore.Field {
+	var masked []zapcore.Field
+	for i, f := range fields {
+		if replacement, changed := r.field(f); changed {
+			if masked == nil {
+				masked = append([]zapcore.Field(nil), fields...)
+			}
+			masked[i] = replacement
+		}
+	}
+	if masked == nil {
+		return fields
+	}
+	return masked
+}
+
+// redactCore masks entries before the wrapped core encodes them.
+type redactCore struct {
+	zapcore.Core
+	redactor *redactor
+}
+
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{Core: c.Core.With(c.redactor.fieldsOf(fields)), redactor: c.redactor}
+}
+
+func (c redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.redactor.value(entry.Message)
+	return c.Core.Write(entry, c.redactor.fieldsOf(fields))
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"test/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var sender Sender = NewMemorySender()
+
+func SetSender(s Sender) {
+	sender = s
+}
+
+func Send(ctx context.Context, msg Message) error {
+	return sender.Send(ctx, msg)
+}
+
+// New builds the sender selected by cfg.Driver.
+func New(cfg config.MailConfig) (Sender, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return &SMTPSender{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.From,
+		}, nil
+	case "file":
+		return &FileSender{Dir: cfg.Dir, From: cfg.From}, nil
+	case "memory":
+		return NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,94 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"test/logging"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	queueSize   = 100
+	sendTimeout = 30 * time.Second
+)
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+// queue feeds the running Worker. It is nil while there is none, and Enqueue
+// then sends directly, which is what the commands and tests rely on.
+var (
+	queueMu sync.RWMutex
+	queue   chan Message
+)
+
+// Enqueue hands msg to the worker when one is running, so the request does
+// not wait on the mail server and its timing does not depend on whether a
+// message was sent. Without a worker it sends msg directly.
+func Enqueue(ctx context.Context, msg Message) error {
+	// The read lock is held across the send so Run cannot finish draining
+	// between our check and the send.
+	queueMu.RLock()
+	defer queueMu.RUnlock()
+
+	if queue == nil {
+		return Send(ctx, msg)
+	}
+	select {
+	case queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Worker sends enqueued messages in the background.
+type Worker struct {
+	messages chan Message
+}
+
+// NewWorker starts queueing messages passed to Enqueue; call Run to send
+// them.
+func NewWorker() *Worker {
+	w := &Worker{messages: make(chan Message, queueSize)}
+	queueMu.Lock()
+	queue = w.messages
+	queueMu.Unlock()
+	return w
+}
+
+// Run sends queued messages until ctx is cancelled, then stops queueing,
+// sends the messages still waiting and returns.
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		select {
+		case msg := <-w.messages:
+			deliver(msg)
+		case <-ctx.Done():
+			queueMu.Lock()
+			if queue == w.messages {
+				queue = nil
+			}
+			queueMu.Unlock()
+			for {
+				select {
+				case msg := <-w.messages:
+					deliver(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func deliver(msg Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	if err := Send(ctx, msg); err != nil {
+		logging.Error("Failed to send mail", zap.String("subject", msg.Subject), zap.Error(err))
+	}
+}
@@ -0,0 +1,67 @@
+package mail_test
+
+import (
+	"context"
+	"sync"
+	"test/mail"
+	"testing"
+)
+
+// blockingSender holds every message until release is closed.
+type blockingSender struct {
+	release chan struct{}
+	mu      sync.Mutex
+	sent    []string
+}
+
+func (s *blockingSender) Send(ctx context.Context, msg mail.Message) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg.Subject)
+	return nil
+}
+
+func TestWorkerSendsInTheBackground(t *testing.T) {
+	sender := &blockingSender{release: make(chan struct{})}
+	mail.SetSender(sender)
+	t.Cleanup(func() { mail.SetSender(mail.NewMemorySender()) })
+
+	worker := mail.NewWorker()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		worker.Run(ctx)
+	}()
+
+	// The sender blocks, so these only return because they are queued.
+	for _, subject := range []string{"first", "second"} {
+		if err := mail.Enqueue(context.Background(), mail.Message{Subject: subject}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cancel()
+	close(sender.release)
+	<-stopped
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if len(sender.sent) != 2 || sender.sent[0] != "first" || sender.sent[1] != "second" {
+		t.Errorf("sent %v, want both queued messages delivered before Run returned", sender.sent)
+	}
+}
+
+func TestEnqueueSendsDirectlyWithoutWorker(t *testing.T) {
+	sender := mail.NewMemorySender()
+	mail.SetSender(sender)
+	t.Cleanup(func() { mail.SetSender(mail.NewMemorySender()) })
+
+	if err := mail.Enqueue(context.Background(), mail.Message{Subject: "now"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, ok := sender.Last(); !ok || msg.Subject != "now" {
+		t.Errorf("last message = %v, %v; want it sent immediately", msg, ok)
+	}
+}
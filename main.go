@@ -1,11 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"log"
+	"test/config"
 	"test/controllers"
+	"test/health"
+	"test/lifecycle"
+	"test/lockout"
+	"test/logging"
+	"test/mail"
+	"test/metrics"
 	"test/models"
+	"test/oidc"
+	"test/repository"
 
 	"github.com/joho/godotenv"
 )
@@ -13,17 +30,94 @@ import (
 func main() {
 	godotenv.Load()
 
-	handler := controllers.New()
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	os.Exit(serve(os.Args[1:]))
+}
+
+// serve runs the server until SIGINT or SIGTERM and returns the exit code.
+// Everything the server depends on is set up before it starts listening.
+func serve(args []string) int {
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		log.Println(err)
+		return 2
+	}
+	if err := logging.Configure(cfg.Logging); err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	sender, err := mail.New(cfg.Mail)
+	if err != nil {
+		log.Printf("Invalid mail configuration: %v", err)
+		return 1
+	}
+	mail.SetSender(sender)
+
+	oidc.Configure(cfg.OIDC, cfg.Server.BaseURL)
+
+	if err := models.ConnectDatabase(cfg.Database); err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	app := lifecycle.New(cfg.Server.ShutdownTimeout)
+	app.OnShutdown("logging", func(context.Context) error {
+		return logging.Close()
+	})
+	app.OnShutdown("database", func(context.Context) error {
+		return models.CloseDatabase()
+	})
+
+	if err := metrics.InstrumentDB(models.DB); err != nil {
+		log.Println(err)
+		app.Shutdown()
+		return 1
+	}
+
+	if cfg.Lockout.Store == "db" {
+		controllers.SetLoginGuard(lockout.NewGuard(lockout.NewGormStore(models.DB)))
+	}
+
+	listener, err := net.Listen("tcp", cfg.Server.Addr())
+	if err != nil {
+		log.Println(err)
+		app.Shutdown()
+		return 1
+	}
 
 	server := &http.Server{
-		Addr:    "0.0.0.0:8008",
-		Handler: handler,
+		Handler: controllers.New(controllers.Deps{
+			Config:       cfg,
+			Repositories: repository.NewGorm(models.DB),
+		}),
 	}
 
-	// fmt.Print("Running on port ", server.Addr)
-	log.Println("Server started on :8008")
+	app.Go("overdue sweeper", runOverdueSweeper)
+	app.Go("mail", mail.NewWorker().Run)
 
-	models.ConnectDatabase()
+	migrator, err := models.Migrator(models.DB)
+	if err != nil {
+		log.Println(err)
+		app.Shutdown()
+		return 1
+	}
+	health.Register("database", models.PingDatabase)
+	health.Register("migrations", migrator.Check)
+	health.Register("workers", app.CheckWorkers)
 
-	server.ListenAndServe()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.Serve(ctx, server, listener); err != nil {
+		log.Println(err)
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+const startKey = "metrics:start"
+
+// registrar is the part of GORM's callback API needed here; the callback
+// type itself is unexported.
+type registrar interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// InstrumentDB times every statement run through db and exports the
+// connection pool statistics of its underlying *sql.DB.
+func InstrumentDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := registry.Register(collectors.NewDBStatsCollector(sqlDB, db.Dialector.Name())); err != nil {
+		return err
+	}
+
+	callbacks := db.Callback()
+	hooks := []struct {
+		operation     string
+		before, after registrar
+	}{
+		{"create", callbacks.Create().Before("*"), callbacks.Create().After("*")},
+		{"query", callbacks.Query().Before("*"), callbacks.Query().After("*")},
+		{"update", callbacks.Update().Before("*"), callbacks.Update().After("*")},
+		{"delete", callbacks.Delete().Before("*"), callbacks.Delete().After("*")},
+		{"row", callbacks.Row().Before("*"), callbacks.Row().After("*")},
+		{"raw", callbacks.Raw().Before("*"), callbacks.Raw().After("*")},
+	}
+	for _, hook := range hooks {
+		if err := hook.before.Register("metrics:before_"+hook.operation, startTimer); err != nil {
+			return err
+		}
+		if err := hook.after.Register("metrics:after_"+hook.operation, observeQuery(hook.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startTimer(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		dbQueryDuration.WithLabelValues(operation, db.Statement.Table).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,152 @@
+// Package metrics collects Prometheus metrics for HTTP traffic, the database
+// and business events, and serves them at /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds only this package's metrics plus the Go runtime and process
+// collectors, so nothing registered by libraries leaks into the output.
+var registry = prometheus.NewRegistry()
+
+// UnmatchedRoute labels requests that matched no route, so scanners cannot
+// create a series per path.
+const UnmatchedRoute = "unmatched"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time taken by database statements, by operation and table.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_registrations_total",
+		Help: "Accounts created, by source: password or oidc.",
+	}, []string{"source"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_logins_total",
+		Help: "Login attempts by result: success or failure.",
+	}, []string{"result"})
+
+	questsCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "quests_completed_total",
+		Help: "Quests completed by users.",
+	})
+
+	pointsAwarded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "quest_points_awarded_total",
+		Help: "Points awarded for completed quests.",
+	})
+
+	stockMovements = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "inventory_stock_movements_total",
+		Help: "Changes to product quantities, by direction: in or out.",
+	}, []string{"direction"})
+	stockQuantity = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "inventory_stock_moved_quantity_total",
+		Help: "Units of product added or removed, by direction: in or out.",
+	}, []string{"direction"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		dbQueryDuration,
+		registrations,
+		logins,
+		questsCompleted,
+		pointsAwarded,
+		stockMovements,
+		stockQuantity,
+	)
+}
+
+// Handler serves every metric in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// OtherMethod labels requests whose method is not a standard one, since the
+// method is chosen by the client.
+const OtherMethod = "OTHER"
+
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// ObserveRequest records one served request. route is the matched route
+// template, or "" if none matched.
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	if !standardMethods[method] {
+		method = OtherMethod
+	}
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+func UserRegistered(source string) {
+	registrations.WithLabelValues(source).Inc()
+}
+
+func LoginSucceeded() {
+	logins.WithLabelValues("success").Inc()
+}
+
+func LoginFailed() {
+	logins.WithLabelValues("failure").Inc()
+}
+
+func QuestCompleted(reward int) {
+	questsCompleted.Inc()
+	if reward > 0 {
+		pointsAwarded.Add(float64(reward))
+	}
+}
+
+// StockMoved records a change of a product's quantity by delta, counting
+// both the movement and the units moved.
+func StockMoved(delta int) {
+	switch {
+	case delta > 0:
+		stockMovements.WithLabelValues("in").Inc()
+		stockQuantity.WithLabelValues("in").Add(float64(delta))
+	case delta < 0:
+		stockMovements.WithLabelValues("out").Inc()
+		stockQuantity.WithLabelValues("out").Add(float64(-delta))
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"test/logging"
+	"test/metrics"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds IDs taken from clients, which end up in every log
+// line for the request.
+const maxRequestIDLength = 128
+
+// statusRecorder remembers the route, status and size of the response for
+// the access log and metrics.
+type statusRecorder struct {
+	http.ResponseWriter
+	route  string
+	status int
+	bytes  int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Instrument gives every request an ID, taken from the X-Request-ID header
+// when the client sent a usable one, echoes it in the response and stores a
+// logger carrying it in the request context. When the request finishes it
+// writes one access log line and records the request metrics.
+func Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := logging.NewContext(r.Context(), logging.Logger().With(
+			zap.String("requestID", id),
+			zap.String("method", r.Method),
+		))
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		latency := time.Since(start)
+		logging.FromContext(ctx).Info("Request",
+			zap.String("path", r.URL.Path),
+			zap.Int("status", recorder.status),
+			zap.Int64("bytes", recorder.bytes),
+			zap.Duration("latency", latency),
+		)
+		metrics.ObserveRequest(r.Method, recorder.route, recorder.status, latency)
+	})
+}
+
+// RecordRoute passes the matched route template to Instrument and adds it
+// to the request logger. It must be installed with Router.Use so the route is
+// known when it runs.
+func RecordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				if recorder, ok := w.(*statusRecorder); ok {
+					recorder.route = template
+				}
+				logging.AddFields(r.Context(), zap.String("route", template))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
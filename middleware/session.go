@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	SessionCookieName = "session"
+	CSRFFieldName     = "csrf_token"
+	CSRFHeaderName    = "X-CSRF-Token"
+)
+
+func SetSessionCookie(w http.ResponseWriter, r *http.Request, token string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func sessionFromCookie(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// csrfToken is derived from the session token, so it needs no storage and
+// changes whenever the session does.
+func (a *Authenticator) csrfToken(session string) string {
+	return a.signer.Digest("csrf", session)
+}
+
+// CSRFTokenFromRequest returns the CSRF token RequireSession derived for the
+// request's session, or "" outside such routes.
+func CSRFTokenFromRequest(r *http.Request) string {
+	token, _ := r.Context().Value(csrfKey).(string)
+	return token
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func (a *Authenticator) validCSRF(r *http.Request, session string) bool {
+	if safeMethod(r.Method) {
+		return true
+	}
+	submitted := r.Header.Get(CSRFHeaderName)
+	if submitted == "" {
+		submitted = r.PostFormValue(CSRFFieldName)
+	}
+	expected := a.csrfToken(session)
+	return submitted != "" && subtle.ConstantTimeCompare([]byte(submitted), []byte(expected)) == 1
+}
+
+// RequireSession protects server-rendered pages: visitors without a valid
+// session cookie are redirected to the login page.
+func (a *Authenticator) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, ok := sessionFromCookie(r)
+		if !ok {
+			redirectToLogin(w, r)
+			return
+		}
+
+		principal, message := a.authenticateJWT(session)
+		if message != "" {
+			ClearSessionCookie(w)
+			redirectToLogin(w, r)
+			return
+		}
+
+		if !a.validCSRF(r, session) {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		if !allowImpersonation(r, principal) {
+			http.Error(w, "Not allowed while impersonating", http.StatusForbidden)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), csrfKey, a.csrfToken(session)))
+		next.ServeHTTP(w, withPrincipal(r, principal))
+	})
+}
+
+func redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	target := "/login"
+	if safeMethod(r.Method) {
+		target += "?next=" + url.QueryEscape(r.URL.RequestURI())
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
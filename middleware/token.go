@@ -1,71 +1,263 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"test/logging"
+	"test/models"
 	"test/utils"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	csrfKey
+)
+
+// Authenticator checks the session JWTs and API keys presented by requests.
+type Authenticator struct {
+	signer *utils.Signer
+}
+
+func NewAuthenticator(signer *utils.Signer) *Authenticator {
+	return &Authenticator{signer: signer}
+}
+
+// Principal is the authenticated caller. Scopes is nil for user sessions,
+// which may call every endpoint, and set for API keys. ImpersonatorID is the
+// admin acting as UserID, if any.
+type Principal struct {
+	UserID         uint
+	APIKeyID       uint
+	Scopes         []string
+	ImpersonatorID uint
+}
+
+func (p Principal) Impersonating() bool {
+	return p.ImpersonatorID != 0
+}
+
+func (p Principal) HasScope(scope string) bool {
+	if p.APIKeyID == 0 {
+		return true
+	}
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(Principal)
+	return principal, ok
+}
+
+// withPrincipal stores principal in the request context and adds the user
+// to the request logger.
+func withPrincipal(r *http.Request, principal Principal) *http.Request {
+	fields := []zap.Field{zap.Uint("userID", principal.UserID)}
+	if principal.APIKeyID != 0 {
+		fields = append(fields, zap.Uint("apiKeyID", principal.APIKeyID))
+	}
+	if principal.Impersonating() {
+		fields = append(fields, zap.Uint("adminID", principal.ImpersonatorID))
+	}
+	logging.AddFields(r.Context(), fields...)
+	return r.WithContext(context.WithValue(r.Context(), principalKey, principal))
+}
+
+var (
+	routeScopes     = map[*mux.Route]string{}
+	sensitiveRoutes = map[*mux.Route]bool{}
+)
+
+// Scope marks a route as callable with an API key holding scope. Routes
+// without a scope only accept user sessions.
+func Scope(route *mux.Route, scope string) *mux.Route {
+	routeScopes[route] = scope
+	return route
+}
+
+// Sensitive marks a route that must not be called while impersonating a
+// user, such as changing their password.
+func Sensitive(route *mux.Route) *mux.Route {
+	sensitiveRoutes[route] = true
+	return route
+}
+
+func isSensitive(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	return route != nil && sensitiveRoutes[route]
+}
+
+// allowImpersonation logs every request made with an impersonation token and
+// rejects those to sensitive routes.
+func allowImpersonation(r *http.Request, principal Principal) bool {
+	if !principal.Impersonating() {
+		return true
+	}
+	logging.Info("Impersonated request", zap.Uint("userID", principal.UserID), zap.Uint("adminID", principal.ImpersonatorID),
+		zap.String("method", r.Method), zap.String("path", r.URL.Path))
+	return !isSensitive(r)
+}
+
+func (a *Authenticator) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+			principal, err := authenticateAPIKey(apiKey, time.Now())
+			if err != nil {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Invalid API key")
+				return
+			}
+
+			scope := ""
+			if route := mux.CurrentRoute(r); route != nil {
+				scope = routeScopes[route]
+			}
+			if scope == "" || !principal.HasScope(scope) {
+				utils.RespondWithError(w, http.StatusForbidden, "API key is not allowed to call this endpoint")
+				return
+			}
+
+			next.ServeHTTP(w, withPrincipal(r, principal))
+			return
+		}
+
 		tokenString := r.Header.Get("Authorization")
+		fromCookie := false
+		if tokenString == "" {
+			tokenString, fromCookie = sessionFromCookie(r)
+		}
 
 		if tokenString == "" {
 			utils.RespondWithError(w, http.StatusUnauthorized, "Missing authorization token")
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte("your-secret-key"), nil
-		})
-
-		if err != nil || !token.Valid {
-			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid authorization token")
+		principal, message := a.authenticateJWT(tokenString)
+		if message != "" {
+			utils.RespondWithError(w, http.StatusUnauthorized, message)
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token claims")
+		if fromCookie && !a.validCSRF(r, tokenString) {
+			utils.RespondWithError(w, http.StatusForbidden, "Invalid CSRF token")
 			return
 		}
 
-		expirationTime := time.Unix(int64(claims["exp"].(float64)), 0)
-		if time.Now().After(expirationTime) {
-			utils.RespondWithError(w, http.StatusUnauthorized, "Token has expired")
+		if !allowImpersonation(r, principal) {
+			utils.RespondWithError(w, http.StatusForbidden, "Not allowed while impersonating")
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, withPrincipal(r, principal))
 	})
 }
 
-func GetUserIdFromToken(r *http.Request) (uint, error) {
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
-		return 0, errors.New("missing authorization token")
-	}
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte("your-secret-key"), nil
-	})
+// authenticateJWT validates a session token and returns the principal, or a
+// client-facing message describing why it was rejected.
+func (a *Authenticator) authenticateJWT(tokenString string) (Principal, string) {
+	token, err := a.signer.ParseJWT(tokenString)
 
 	if err != nil || !token.Valid {
-		return 0, errors.New("invalid or expired authorization token")
+		return Principal{}, "Invalid authorization token"
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return 0, errors.New("invalid token claims")
+		return Principal{}, "Invalid token claims"
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Now().After(time.Unix(int64(exp), 0)) {
+		return Principal{}, "Token has expired"
+	}
+
+	if !sessionIsCurrent(claims) {
+		return Principal{}, "Session has been revoked"
+	}
+
+	principal := Principal{UserID: uint(claims["userID"].(float64))}
+	if _, ok := claims["act"]; ok {
+		adminID, ok := impersonatorIsCurrent(claims)
+		if !ok {
+			return Principal{}, "Session has been revoked"
+		}
+		principal.ImpersonatorID = adminID
+	}
+	return principal, ""
+}
+
+// apiKeyTouchInterval limits how often last_used_at is written for busy keys.
+const apiKeyTouchInterval = time.Minute
+
+func authenticateAPIKey(raw string, now time.Time) (Principal, error) {
+	var key models.APIKey
+	if err := models.DB.Where("key_hash = ?", models.HashToken(raw)).First(&key).Error; err != nil {
+		return Principal{}, err
+	}
+	if !key.Active(now) {
+		return Principal{}, errors.New("API key is revoked or expired")
 	}
 
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		models.DB.Model(&key).UpdateColumn("last_used_at", now)
+	}
+
+	return Principal{UserID: key.UserID, APIKeyID: key.ID, Scopes: key.ScopeList()}, nil
+}
+
+// sessionIsCurrent rejects tokens issued before the user's sessions were
+// revoked, e.g. by a password change.
+func sessionIsCurrent(claims jwt.MapClaims) bool {
 	userID, ok := claims["userID"].(float64)
 	if !ok {
-		return 0, errors.New("user ID not found in token claims")
+		return false
 	}
+	version, _ := claims["ver"].(float64)
 
-	return uint(userID), nil
+	var user models.Users
+	if err := models.DB.Select("id", "session_version").Where("id = ?", uint(userID)).First(&user).Error; err != nil {
+		return false
+	}
+	return uint(version) == user.SessionVersion
+}
+
+// impersonatorIsCurrent checks that the admin named in an impersonation
+// token is still an admin and has not revoked their own sessions since.
+func impersonatorIsCurrent(claims jwt.MapClaims) (uint, bool) {
+	adminID, ok := claims["act"].(float64)
+	if !ok {
+		return 0, false
+	}
+	version, _ := claims["act_ver"].(float64)
+
+	var admin models.Users
+	if err := models.DB.Select("id", "role", "session_version").Where("id = ?", uint(adminID)).First(&admin).Error; err != nil {
+		return 0, false
+	}
+	if admin.Role != models.RoleAdmin || uint(version) != admin.SessionVersion {
+		return 0, false
+	}
+	return admin.ID, true
+}
+
+// GetUserIdFromToken returns the user the request acts as. For an
+// impersonation token this is the impersonated user, not the admin. It only
+// works behind AuthMiddleware or RequireSession.
+func GetUserIdFromToken(r *http.Request) (uint, error) {
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		return 0, errors.New("request is not authenticated")
+	}
+	return principal.UserID, nil
 }
@@ -0,0 +1,160 @@
+package migrations_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"test/migrations"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// baselineSchema is what AutoMigrate created before migrations existed.
+const baselineSchema = `
+CREATE TABLE "users" (
+    "id" bigserial, "username" text, "email" text, "password" text, "token" text,
+    "point" bigint, "created_at" timestamptz, "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE TABLE "quests" (
+    "id" bigserial, "title" text, "description" text, "reward" bigint, "user_id" bigint,
+    "created_at" timestamptz, "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_quests" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE TABLE "completed_quests" (
+    "id" bigserial, "user_id" bigint, "quest_id" bigint, "completed_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_completed_quests" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE TABLE "uoms" (
+    "id" bigserial, "name" text, "user_id" bigint, "created_at" timestamptz, "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE TABLE "products" (
+    "id" bigserial, "name" text, "user_id" bigint, "qty" bigint, "uom_id" bigint,
+    "created_at" timestamptz, "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_products_uom" FOREIGN KEY ("uom_id") REFERENCES "uoms"("id")
+);`
+
+// openPostgres connects to TEST_POSTGRES_URL with a fresh schema first on the
+// search path, dropped when t ends.
+func openPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA "` + schema + `"`); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA "` + schema + `" CASCADE`) })
+
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("TEST_POSTGRES_URL must be a URL: %v", err)
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema)
+	parsed.RawQuery = query.Encode()
+
+	db, err := sql.Open("pgx", parsed.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestUpAdoptsBaselineDatabase(t *testing.T) {
+	db := openPostgres(t)
+	ctx := context.Background()
+
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	_, err := db.Exec(`INSERT INTO "users" ("username", "email", "password", "point", "created_at", "updated_at")
+		VALUES ('alice', 'alice@example.com', 'secret', 10, $1, $1)`, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO "quests" ("title", "reward", "user_id") VALUES ('Old quest', 5, 1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrations.New(db, "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx, 3); err != nil {
+		t.Fatalf("adopting a baseline database: %v", err)
+	}
+	// Signed up between adoption and the backfill, still waiting for the link.
+	_, err = db.Exec(`INSERT INTO "users" ("username", "email", "created_at", "verification_sent_at")
+		VALUES ('bob', 'bob@example.com', $1, $1)`, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("upgrading an adopted database: %v", err)
+	}
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var role string
+	var sessionVersion int
+	var totpEnabled sql.NullBool
+	var verifiedAt sql.NullTime
+	err = db.QueryRow(`SELECT "role", "session_version", "totp_enabled", "email_verified_at" FROM "users" WHERE "username" = 'alice'`).
+		Scan(&role, &sessionVersion, &totpEnabled, &verifiedAt)
+	if err != nil {
+		t.Fatalf("reading upgraded user: %v", err)
+	}
+	if role != "player" || sessionVersion != 0 || totpEnabled.Bool {
+		t.Errorf("upgraded user has role %q, session version %d, totp %v", role, sessionVersion, totpEnabled)
+	}
+	if !verifiedAt.Valid || !verifiedAt.Time.Equal(created) {
+		t.Errorf("existing account verified at %v, want its creation time %v", verifiedAt, created)
+	}
+
+	if err := db.QueryRow(`SELECT "email_verified_at" FROM "users" WHERE "username" = 'bob'`).Scan(&verifiedAt); err != nil {
+		t.Fatal(err)
+	}
+	if verifiedAt.Valid {
+		t.Errorf("account awaiting verification was marked verified at %v", verifiedAt.Time)
+	}
+
+	var status, difficulty string
+	var minLng sql.NullFloat64
+	var categoryID sql.NullInt64
+	err = db.QueryRow(`SELECT "status", "difficulty", "geofence_min_lng", "category_id" FROM "quests"`).
+		Scan(&status, &difficulty, &minLng, &categoryID)
+	if err != nil {
+		t.Fatalf("reading upgraded quest: %v", err)
+	}
+	if status != "active" || difficulty != "medium" || minLng.Valid || categoryID.Valid {
+		t.Errorf("upgraded quest has status %q, difficulty %q, bounds %v, category %v", status, difficulty, minLng, categoryID)
+	}
+
+	if _, err := db.Exec(`INSERT INTO "categories" ("name") VALUES ('Outdoor')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE "quests" SET "category_id" = 99`); err == nil {
+		t.Error("quests.category_id accepted a missing category")
+	}
+}
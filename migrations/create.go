@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create writes an empty up and down file for the next version into each
+// dialect directory under dir and returns their paths. The files are
+// embedded on the next build.
+func Create(dir, name string) ([]string, error) {
+	name = strings.Trim(nonWord.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, fmt.Errorf("migration name must contain letters or digits")
+	}
+
+	version := 1
+	for _, dialect := range Dialects {
+		existing, err := load(os.DirFS(filepath.Join(dir, dialect)), ".")
+		if err != nil {
+			return nil, err
+		}
+		if n := len(existing); n > 0 && existing[n-1].Version >= version {
+			version = existing[n-1].Version + 1
+		}
+	}
+
+	var paths []string
+	for _, dialect := range Dialects {
+		base := filepath.Join(dir, dialect, fmt.Sprintf("%04d_%s", version, name))
+		for _, path := range []string{base + ".up.sql", base + ".down.sql"} {
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if err != nil {
+				return paths, err
+			}
+			file.Close()
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,110 @@
+// Package migrations applies the versioned SQL files embedded from one
+// directory per database driver, postgres/ and sqlite/. Each version has a
+// NNNN_name.up.sql and a NNNN_name.down.sql file in every directory; applied
+// versions are recorded with a checksum of their up file in
+// schema_migrations.
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Dir is the source directory of the embedded files, relative to the
+// repository root. New migrations are created in each of its dialect
+// subdirectories.
+const Dir = "migrations"
+
+// Dialects lists the supported drivers, which are also the names of the
+// subdirectories holding their migrations.
+var Dialects = []string{"postgres", "sqlite"}
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// All returns the embedded migrations for dialect in version order.
+func All(dialect string) ([]Migration, error) {
+	if !knownDialect(dialect) {
+		return nil, fmt.Errorf("no migrations for database driver %q", dialect)
+	}
+	return load(files, dialect)
+}
+
+func knownDialect(dialect string) bool {
+	for _, known := range Dialects {
+		if known == dialect {
+			return true
+		}
+	}
+	return false
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_name.up.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two names, %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("migration %s has no up file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
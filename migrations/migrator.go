@@ -0,0 +1,245 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"test/logging"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// lockKey is the pg_advisory_lock key held while migrating, so instances
+// starting together apply each migration once.
+const lockKey = 4203981572
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// Status is one migration as known to the binary, the database or both.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// Modified is set when the applied up file differs from the embedded one.
+	Modified bool
+	// Unknown is set when the database has a version this binary lacks.
+	Unknown bool
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// New returns a migrator for db, whose driver is dialect, one of Dialects.
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := All(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Up applies up to steps pending migrations, or all of them when steps is
+// zero, and returns the ones it applied. It refuses to run if an applied
+// migration was edited afterwards.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		for _, migration := range m.migrations {
+			if record, ok := applied[migration.Version]; ok && record.checksum != migration.Checksum {
+				return fmt.Errorf("migration %s was modified after it was applied", migration)
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if steps > 0 && len(done) == steps {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := m.apply(ctx, conn, migration, migration.Up,
+				"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)",
+				migration.Version, migration.Name, migration.Checksum, time.Now())
+			if err != nil {
+				return err
+			}
+			logging.Info("Migration applied", zap.String("migration", migration.String()))
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %s has no down file", migration)
+			}
+
+			err := m.apply(ctx, conn, migration, migration.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			if err != nil {
+				return err
+			}
+			logging.Info("Migration reverted", zap.String("migration", migration.String()))
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every embedded migration and any applied version the binary
+// does not know, in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if record, ok := applied[migration.Version]; ok {
+				appliedAt := record.appliedAt
+				status.AppliedAt = &appliedAt
+				status.Modified = record.checksum != migration.Checksum
+				delete(applied, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for version, record := range applied {
+			appliedAt := record.appliedAt
+			statuses = append(statuses, Status{Version: version, Name: record.name, AppliedAt: &appliedAt, Unknown: true})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, err
+}
+
+// locked runs fn on a single connection holding the advisory lock, after
+// making sure schema_migrations exists. SQLite has no advisory locks; its
+// databases are local to one process, so none is taken.
+func (m *Migrator) locked(ctx context.Context, fn func(*sql.Conn, map[int]appliedMigration) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	timestampType := "datetime"
+	if m.dialect == "postgres" {
+		timestampType = "timestamptz"
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		defer func() {
+			// The lock belongs to the session, so it must be released even
+			// if ctx is already done.
+			_, unlockErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+			err = errors.Join(err, unlockErr)
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		checksum text NOT NULL,
+		applied_at `+timestampType+` NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := readApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, applied)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func readApplied(ctx context.Context, db querier) (map[int]appliedMigration, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var record appliedMigration
+		if err := rows.Scan(&version, &record.name, &record.checksum, &record.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = record
+	}
+	return applied, rows.Err()
+}
+
+// Check reports an error if any embedded migration is not applied or was
+// modified after it was applied. Unlike Status it takes no lock and creates
+// nothing, so it is cheap enough for readiness probes.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := readApplied(ctx, m.db)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, migration := range m.migrations {
+		record, ok := applied[migration.Version]
+		if !ok {
+			pending = append(pending, migration.String())
+			continue
+		}
+		if record.checksum != migration.Checksum {
+			return fmt.Errorf("migration %s was modified after it was applied", migration)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// apply runs one migration file and its schema_migrations bookkeeping in a
+// single transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %s: %w", migration, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("migration %s: %w", migration, err)
+	}
+	return tx.Commit()
+}
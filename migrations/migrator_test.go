@@ -0,0 +1,122 @@
+package migrations_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"test/migrations"
+	"testing"
+
+	// Registers the "sqlite" database/sql driver the app uses through GORM.
+	_ "github.com/glebarez/sqlite"
+)
+
+// openSQLite opens a fresh database file; an in-memory one would not be
+// shared between the pool's connections.
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "quests.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestUpOnSQLite(t *testing.T) {
+	db := openSQLite(t)
+	ctx := context.Background()
+
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := migrations.All("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.Check(ctx); err == nil {
+		t.Error("Check passed on an empty database")
+	}
+
+	applied, err := migrator.Up(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0].Version != all[0].Version {
+		t.Fatalf("Up(1) applied %v, want only %s", applied, all[0])
+	}
+	if err := migrator.Check(ctx); err == nil || !strings.Contains(err.Error(), "pending migrations") {
+		t.Errorf("Check after a partial Up = %v, want pending migrations", err)
+	}
+
+	applied, err = migrator.Up(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(all)-1 {
+		t.Errorf("Up applied %d migrations, want the remaining %d", len(applied), len(all)-1)
+	}
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("Check after Up: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO "users" ("username", "email") VALUES ('alice', 'alice@example.com')`); err != nil {
+		t.Errorf("migrated users table rejected a row: %v", err)
+	}
+
+	applied, err = migrator.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("re-running Up: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("re-running Up applied %v, want nothing", applied)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != len(all) {
+		t.Fatalf("Status lists %d migrations, want %d", len(statuses), len(all))
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil || status.Modified || status.Unknown {
+			t.Errorf("status of %04d_%s = %+v, want applied and unmodified", status.Version, status.Name, status)
+		}
+	}
+}
+
+func TestModifiedMigrationOnSQLite(t *testing.T) {
+	db := openSQLite(t)
+	ctx := context.Background()
+
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	// Stands in for editing an up file after it was applied.
+	if _, err := db.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.Check(ctx); err == nil || !strings.Contains(err.Error(), "was modified after it was applied") {
+		t.Errorf("Check = %v, want a modified migration", err)
+	}
+	if _, err := migrator.Up(ctx, 0); err == nil || !strings.Contains(err.Error(), "was modified after it was applied") {
+		t.Errorf("Up = %v, want it to refuse a modified migration", err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Modified {
+		t.Errorf("status of the first migration = %+v, want modified", statuses[0])
+	}
+}
@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS "login_attempts";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "user_identities";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "password_reset_tokens";
+DROP TABLE IF EXISTS "quest_assignments";
+DROP TABLE IF EXISTS "quest_template_tags";
+DROP TABLE IF EXISTS "quest_templates";
+DROP TABLE IF EXISTS "products";
+DROP TABLE IF EXISTS "uoms";
+DROP TABLE IF EXISTS "completed_quests";
+DROP TABLE IF EXISTS "quest_tags";
+DROP TABLE IF EXISTS "tags";
+DROP TABLE IF EXISTS "quests";
+DROP TABLE IF EXISTS "categories";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,43 @@
+-- Databases created by AutoMigrate before migrations existed already have
+-- the tables of 0001, which then skipped them, but only the columns of the
+-- original users and quests models. Add what was introduced since.
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "role" text DEFAULT 'player';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "email_verified_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "verification_sent_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "session_version" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_secret" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_enabled" boolean;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_last_step" bigint;
+
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "geofence_type" text;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "geofence_latitude" decimal;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "geofence_longitude" decimal;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "geofence_radius" decimal;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "geofence_polygon" text;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "geofence_min_lat" decimal;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "geofence_max_lat" decimal;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "geofence_min_lng" decimal;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "geofence_max_lng" decimal;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "difficulty" text;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "category_id" bigint;
+ALTER TABLE "quests" ADD COLUMN IF NOT EXISTS "status" text NOT NULL DEFAULT 'active';
+
+-- New quests default to medium; give existing ones the same.
+UPDATE "quests" SET "difficulty" = 'medium' WHERE "difficulty" IS NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_quests_category' AND conrelid = '"quests"'::regclass) THEN
+        ALTER TABLE "quests" ADD CONSTRAINT "fk_quests_category"
+            FOREIGN KEY ("category_id") REFERENCES "categories"("id");
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS "idx_quests_min_lat" ON "quests" ("geofence_min_lat");
+CREATE INDEX IF NOT EXISTS "idx_quests_max_lat" ON "quests" ("geofence_max_lat");
+CREATE INDEX IF NOT EXISTS "idx_quests_min_lng" ON "quests" ("geofence_min_lng");
+CREATE INDEX IF NOT EXISTS "idx_quests_max_lng" ON "quests" ("geofence_max_lng");
+CREATE INDEX IF NOT EXISTS "idx_quests_difficulty" ON "quests" ("difficulty");
+CREATE INDEX IF NOT EXISTS "idx_quests_category_id" ON "quests" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_quests_status" ON "quests" ("status");
@@ -0,0 +1,7 @@
+-- Circle geofences near the antimeridian stored longitude bounds past ±180,
+-- which the nearby lookup never matched. Such boxes now span every longitude.
+UPDATE "quests" SET "geofence_min_lng" = -180, "geofence_max_lng" = 180
+WHERE "geofence_min_lng" < -180 OR "geofence_max_lng" > 180;
+
+UPDATE "quest_templates" SET "geofence_min_lng" = -180, "geofence_max_lng" = 180
+WHERE "geofence_min_lng" < -180 OR "geofence_max_lng" > 180;
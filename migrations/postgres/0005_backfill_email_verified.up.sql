@@ -0,0 +1,7 @@
+-- Accounts created before email verification existed are grandfathered in.
+-- They predate 0001, which adopted their database, and were never sent a
+-- verification email; accounts still waiting to verify were.
+UPDATE "users" SET "email_verified_at" = "created_at"
+WHERE "email_verified_at" IS NULL
+  AND "verification_sent_at" IS NULL
+  AND "created_at" < (SELECT "applied_at" FROM "schema_migrations" WHERE "version" = 1);
@@ -0,0 +1,227 @@
+-- SQLite version of postgres/0001_initial.up.sql. Keep the two in step.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "username" text,
+    "email" text,
+    "password" text,
+    "token" text,
+    "point" integer,
+    "role" text DEFAULT 'player',
+    "email_verified_at" datetime,
+    "verification_sent_at" datetime,
+    "session_version" integer NOT NULL DEFAULT 0,
+    "totp_secret" text,
+    "totp_enabled" numeric,
+    "totp_last_step" integer,
+    "created_at" datetime,
+    "updated_at" datetime
+);
+
+CREATE TABLE IF NOT EXISTS "categories" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "name" text,
+    "parent_id" integer,
+    "created_at" datetime,
+    "updated_at" datetime,
+    CONSTRAINT "fk_categories_children" FOREIGN KEY ("parent_id") REFERENCES "categories"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_categories_parent_id" ON "categories" ("parent_id");
+
+CREATE TABLE IF NOT EXISTS "quests" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "title" text,
+    "description" text,
+    "reward" integer,
+    "user_id" integer,
+    "geofence_type" text,
+    "geofence_latitude" real,
+    "geofence_longitude" real,
+    "geofence_radius" real,
+    "geofence_polygon" text,
+    "geofence_min_lat" real,
+    "geofence_max_lat" real,
+    "geofence_min_lng" real,
+    "geofence_max_lng" real,
+    "difficulty" text,
+    "category_id" integer,
+    "status" text NOT NULL DEFAULT 'active',
+    "created_at" datetime,
+    "updated_at" datetime,
+    CONSTRAINT "fk_quests_category" FOREIGN KEY ("category_id") REFERENCES "categories"("id"),
+    CONSTRAINT "fk_users_quests" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_quests_min_lat" ON "quests" ("geofence_min_lat");
+CREATE INDEX IF NOT EXISTS "idx_quests_max_lat" ON "quests" ("geofence_max_lat");
+CREATE INDEX IF NOT EXISTS "idx_quests_min_lng" ON "quests" ("geofence_min_lng");
+CREATE INDEX IF NOT EXISTS "idx_quests_max_lng" ON "quests" ("geofence_max_lng");
+CREATE INDEX IF NOT EXISTS "idx_quests_difficulty" ON "quests" ("difficulty");
+CREATE INDEX IF NOT EXISTS "idx_quests_category_id" ON "quests" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_quests_status" ON "quests" ("status");
+
+CREATE TABLE IF NOT EXISTS "tags" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "name" text NOT NULL,
+    "created_at" datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tags_name" ON "tags" ("name");
+
+CREATE TABLE IF NOT EXISTS "quest_tags" (
+    "quest_id" integer,
+    "tag_id" integer,
+    PRIMARY KEY ("quest_id", "tag_id"),
+    CONSTRAINT "fk_quest_tags_quest" FOREIGN KEY ("quest_id") REFERENCES "quests"("id"),
+    CONSTRAINT "fk_quest_tags_tag" FOREIGN KEY ("tag_id") REFERENCES "tags"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "completed_quests" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer,
+    "quest_id" integer,
+    "completed_at" datetime,
+    CONSTRAINT "fk_users_completed_quests" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "uoms" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "name" text,
+    "user_id" integer,
+    "created_at" datetime,
+    "updated_at" datetime
+);
+
+CREATE TABLE IF NOT EXISTS "products" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "name" text,
+    "user_id" integer,
+    "qty" integer,
+    "uom_id" integer,
+    "created_at" datetime,
+    "updated_at" datetime,
+    CONSTRAINT "fk_products_uom" FOREIGN KEY ("uom_id") REFERENCES "uoms"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "quest_templates" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "name" text,
+    "title" text,
+    "description" text,
+    "reward" integer,
+    "user_id" integer,
+    "geofence_type" text,
+    "geofence_latitude" real,
+    "geofence_longitude" real,
+    "geofence_radius" real,
+    "geofence_polygon" text,
+    "geofence_min_lat" real,
+    "geofence_max_lat" real,
+    "geofence_min_lng" real,
+    "geofence_max_lng" real,
+    "difficulty" text,
+    "category_id" integer,
+    "created_at" datetime,
+    "updated_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_quest_templates_min_lat" ON "quest_templates" ("geofence_min_lat");
+CREATE INDEX IF NOT EXISTS "idx_quest_templates_max_lat" ON "quest_templates" ("geofence_max_lat");
+CREATE INDEX IF NOT EXISTS "idx_quest_templates_min_lng" ON "quest_templates" ("geofence_min_lng");
+CREATE INDEX IF NOT EXISTS "idx_quest_templates_max_lng" ON "quest_templates" ("geofence_max_lng");
+
+CREATE TABLE IF NOT EXISTS "quest_template_tags" (
+    "quest_template_id" integer,
+    "tag_id" integer,
+    PRIMARY KEY ("quest_template_id", "tag_id"),
+    CONSTRAINT "fk_quest_template_tags_quest_template" FOREIGN KEY ("quest_template_id") REFERENCES "quest_templates"("id"),
+    CONSTRAINT "fk_quest_template_tags_tag" FOREIGN KEY ("tag_id") REFERENCES "tags"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "quest_assignments" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "quest_id" integer,
+    "assignee_id" integer,
+    "assigned_by_id" integer,
+    "due_at" datetime,
+    "expire_when_overdue" numeric,
+    "status" text,
+    "started_at" datetime,
+    "completed_at" datetime,
+    "created_at" datetime,
+    "updated_at" datetime,
+    CONSTRAINT "fk_quest_assignments_quest" FOREIGN KEY ("quest_id") REFERENCES "quests"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_quest_assignments_quest_id" ON "quest_assignments" ("quest_id");
+CREATE INDEX IF NOT EXISTS "idx_quest_assignments_assignee_id" ON "quest_assignments" ("assignee_id");
+CREATE INDEX IF NOT EXISTS "idx_quest_assignments_due_at" ON "quest_assignments" ("due_at");
+CREATE INDEX IF NOT EXISTS "idx_quest_assignments_status" ON "quest_assignments" ("status");
+
+CREATE TABLE IF NOT EXISTS "password_reset_tokens" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer,
+    "token_hash" text,
+    "expires_at" datetime,
+    "used_at" datetime,
+    "created_at" datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_password_reset_tokens_token_hash" ON "password_reset_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_password_reset_tokens_user_id" ON "password_reset_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer,
+    "code_hash" text,
+    "used_at" datetime,
+    "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_code_hash" ON "recovery_codes" ("code_hash");
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer,
+    "name" text,
+    "prefix" text,
+    "key_hash" text,
+    "scopes" text,
+    "expires_at" datetime,
+    "last_used_at" datetime,
+    "revoked_at" datetime,
+    "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_prefix" ON "api_keys" ("prefix");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_hash" ON "api_keys" ("key_hash");
+
+CREATE TABLE IF NOT EXISTS "user_identities" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer,
+    "provider" text,
+    "subject" text,
+    "email" text,
+    "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_user_identities_user_id" ON "user_identities" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_identity_provider_subject" ON "user_identities" ("provider", "subject");
+
+CREATE TABLE IF NOT EXISTS "audit_logs" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "actor_id" integer,
+    "action" text,
+    "target_type" text,
+    "target_id" integer,
+    "reason" text,
+    "changes" text,
+    "ip" text,
+    "created_at" datetime,
+    CONSTRAINT "fk_audit_logs_actor" FOREIGN KEY ("actor_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor_id" ON "audit_logs" ("actor_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_target" ON "audit_logs" ("target_type", "target_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+    "key" text,
+    "failures" integer,
+    "last_failure" datetime,
+    PRIMARY KEY ("key")
+);
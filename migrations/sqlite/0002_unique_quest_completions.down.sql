@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "idx_completed_quests_user_quest";
@@ -0,0 +1,8 @@
+-- Quests could be completed repeatedly through the API. Keep the first
+-- completion of each quest per user before enforcing uniqueness.
+DELETE FROM "completed_quests"
+WHERE "id" NOT IN (
+    SELECT MIN("id") FROM "completed_quests" GROUP BY "user_id", "quest_id"
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_completed_quests_user_quest" ON "completed_quests" ("user_id", "quest_id");
@@ -0,0 +1 @@
+-- The columns are part of the schema 0001 creates; nothing to undo.
@@ -0,0 +1,2 @@
+-- SQLite databases were always created by 0001, so there is no older schema
+-- to adopt; see the postgres version of this migration.
@@ -0,0 +1 @@
+-- The original bounds are recomputed whenever a quest is saved; nothing to undo.
@@ -0,0 +1 @@
+-- Verified accounts cannot be told apart from grandfathered ones; nothing to undo.
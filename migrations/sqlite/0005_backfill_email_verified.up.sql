@@ -0,0 +1,3 @@
+-- SQLite databases were always created by 0001, after email verification
+-- existed, so there are no accounts to grandfather in; see the postgres
+-- version of this migration.
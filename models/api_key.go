@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	ScopeQuestsRead     = "quests:read"
+	ScopeQuestsWrite    = "quests:write"
+	ScopeQuestsComplete = "quests:complete"
+	ScopeInventoryRead  = "inventory:read"
+	ScopeInventoryWrite = "inventory:write"
+	ScopeProfileRead    = "profile:read"
+)
+
+var KnownScopes = []string{
+	ScopeQuestsRead,
+	ScopeQuestsWrite,
+	ScopeQuestsComplete,
+	ScopeInventoryRead,
+	ScopeInventoryWrite,
+	ScopeProfileRead,
+}
+
+func ValidScope(scope string) bool {
+	for _, known := range KnownScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primary_key"`
+	UserID     uint       `json:"user_id" gorm:"index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"index"`
+	KeyHash    string     `json:"-" gorm:"uniqueIndex"`
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
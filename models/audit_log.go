@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditTargetUser    = "user"
+	AuditTargetQuest   = "quest"
+	AuditTargetUom     = "uom"
+	AuditTargetProduct = "product"
+	// AuditTargetSystem is for process-wide settings, with target ID 0.
+	AuditTargetSystem = "system"
+)
+
+// AuditLog records an administrative change: who made it, what it touched
+// and, as JSON, the values before and after.
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primary_key"`
+	ActorID    uint      `json:"actor_id" gorm:"index"`
+	Actor      Users     `json:"-" gorm:"foreignkey:ActorID"`
+	Action     string    `json:"action" gorm:"index"`
+	TargetType string    `json:"target_type" gorm:"index:idx_audit_logs_target"`
+	TargetID   uint      `json:"target_id" gorm:"index:idx_audit_logs_target"`
+	Reason     string    `json:"reason"`
+	Changes    string    `json:"changes" gorm:"type:text"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// Change is the before and after value of a single field.
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+func (a *AuditLog) SetChanges(changes map[string]Change) error {
+	if len(changes) == 0 {
+		a.Changes = ""
+		return nil
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	a.Changes = string(encoded)
+	return nil
+}
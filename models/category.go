@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type Category struct {
+	ID        uint       `json:"id" gorm:"primary_key"`
+	Name      string     `json:"name"`
+	ParentID  *uint      `json:"parent_id" gorm:"index"`
+	Children  []Category `json:"children,omitempty" gorm:"foreignkey:ParentID"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// DescendantIDs returns id followed by the ids of every category below it,
+// given the flat list of all categories.
+func DescendantIDs(categories []Category, id uint) []uint {
+	children := map[uint][]uint{}
+	for _, c := range categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+
+	ids := []uint{id}
+	seen := map[uint]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	GeofenceCircle  = "circle"
+	GeofencePolygon = "polygon"
+
+	earthRadiusMeters = 6371000.0
+	metersPerDegree   = 111320.0
+)
+
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// GeoPolygon is stored as a JSON array in a text column so no spatial
+// extension is needed on the database side.
+type GeoPolygon []GeoPoint
+
+func (p GeoPolygon) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (p *GeoPolygon) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported polygon type %T", value)
+	}
+	if len(raw) == 0 {
+		*p = nil
+		return nil
+	}
+	return json.Unmarshal(raw, p)
+}
+
+// Geofence is either a circle (center + radius in meters) or a polygon.
+// The Min/Max columns hold its bounding box so nearby lookups can be
+// narrowed with plain indexed comparisons before the exact distance check.
+type Geofence struct {
+	Type      string     `json:"type,omitempty"`
+	Latitude  float64    `json:"latitude,omitempty"`
+	Longitude float64    `json:"longitude,omitempty"`
+	Radius    float64    `json:"radius,omitempty"`
+	Polygon   GeoPolygon `json:"polygon,omitempty" gorm:"type:text"`
+	MinLat    float64    `json:"-" gorm:"index"`
+	MaxLat    float64    `json:"-" gorm:"index"`
+	MinLng    float64    `json:"-" gorm:"index"`
+	MaxLng    float64    `json:"-" gorm:"index"`
+}
+
+func (g Geofence) Enabled() bool {
+	return g.Type != ""
+}
+
+func (g Geofence) Validate() error {
+	switch g.Type {
+	case "":
+		return nil
+	case GeofenceCircle:
+		if !validCoordinate(g.Latitude, g.Longitude) {
+			return errors.New("geofence center is out of range")
+		}
+		if g.Radius <= 0 {
+			return errors.New("geofence radius must be positive")
+		}
+	case GeofencePolygon:
+		if len(g.Polygon) < 3 {
+			return errors.New("geofence polygon needs at least 3 points")
+		}
+		for _, pt := range g.Polygon {
+			if !validCoordinate(pt.Lat, pt.Lng) {
+				return errors.New("geofence polygon point is out of range")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown geofence type %q", g.Type)
+	}
+	return nil
+}
+
+func (g *Geofence) computeBounds() {
+	switch g.Type {
+	case GeofenceCircle:
+		g.MinLat, g.MaxLat, g.MinLng, g.MaxLng = BoundingBox(g.Latitude, g.Longitude, g.Radius)
+		// One pair of columns cannot hold a box split at the antimeridian,
+		// so such circles cover every longitude and rely on the exact
+		// distance check.
+		if g.MinLng < -180 || g.MaxLng > 180 {
+			g.MinLng, g.MaxLng = -180, 180
+		}
+	case GeofencePolygon:
+		g.MinLat, g.MaxLat = g.Polygon[0].Lat, g.Polygon[0].Lat
+		g.MinLng, g.MaxLng = g.Polygon[0].Lng, g.Polygon[0].Lng
+		for _, pt := range g.Polygon[1:] {
+			g.MinLat = math.Min(g.MinLat, pt.Lat)
+			g.MaxLat = math.Max(g.MaxLat, pt.Lat)
+			g.MinLng = math.Min(g.MinLng, pt.Lng)
+			g.MaxLng = math.Max(g.MaxLng, pt.Lng)
+		}
+	default:
+		g.MinLat, g.MaxLat, g.MinLng, g.MaxLng = 0, 0, 0, 0
+	}
+}
+
+// DistanceTo returns the distance in meters from the point to the edge of
+// the geofence, or 0 when the point lies inside it.
+func (g Geofence) DistanceTo(lat, lng float64) float64 {
+	switch g.Type {
+	case GeofenceCircle:
+		return math.Max(0, Haversine(lat, lng, g.Latitude, g.Longitude)-g.Radius)
+	case GeofencePolygon:
+		if g.containsPoint(lat, lng) {
+			return 0
+		}
+		return g.distanceToEdges(lat, lng)
+	}
+	return math.Inf(1)
+}
+
+// Contains reports whether the point lies inside the geofence. The reading's
+// accuracy is not added to the geofence, so a vague fix earns no extra
+// margin; callers reject readings that are too inaccurate instead.
+func (g Geofence) Contains(lat, lng float64) bool {
+	return g.DistanceTo(lat, lng) == 0
+}
+
+func (g Geofence) containsPoint(lat, lng float64) bool {
+	inside := false
+	n := len(g.Polygon)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		a, b := g.Polygon[i], g.Polygon[j]
+		if (a.Lat > lat) != (b.Lat > lat) &&
+			lng < (b.Lng-a.Lng)*(lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// distanceToEdges projects the polygon onto a local equirectangular plane
+// centered on the point, which is accurate enough for quest-sized areas.
+func (g Geofence) distanceToEdges(lat, lng float64) float64 {
+	cosLat := math.Cos(lat * math.Pi / 180)
+	project := func(pt GeoPoint) (float64, float64) {
+		return (pt.Lng - lng) * metersPerDegree * cosLat, (pt.Lat - lat) * metersPerDegree
+	}
+
+	best := math.Inf(1)
+	n := len(g.Polygon)
+	for i := 0; i < n; i++ {
+		ax, ay := project(g.Polygon[i])
+		bx, by := project(g.Polygon[(i+1)%n])
+		best = math.Min(best, distanceToSegment(ax, ay, bx, by))
+	}
+	return best
+}
+
+func distanceToSegment(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	lenSq := dx*dx + dy*dy
+	t := 0.0
+	if lenSq > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lenSq))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
+
+func Haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLng := (lng2 - lng1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// BoundingBox returns a box around the circle. Near the antimeridian the
+// longitudes extend past ±180; SplitLongitude turns them into valid ranges.
+func BoundingBox(lat, lng, radius float64) (minLat, maxLat, minLng, maxLng float64) {
+	dLat := radius / metersPerDegree
+	dLng := 180.0
+	if cosLat := math.Cos(lat * math.Pi / 180); cosLat > 1e-9 {
+		dLng = math.Min(180, radius/(metersPerDegree*cosLat))
+	}
+	return lat - dLat, lat + dLat, lng - dLng, lng + dLng
+}
+
+// LngRange is a longitude interval within [-180, 180].
+type LngRange struct {
+	Min, Max float64
+}
+
+// SplitLongitude turns [minLng, maxLng], which may extend past ±180, into
+// one or two ranges within [-180, 180].
+func SplitLongitude(minLng, maxLng float64) []LngRange {
+	switch {
+	case maxLng-minLng >= 360:
+		return []LngRange{{-180, 180}}
+	case minLng < -180:
+		return []LngRange{{minLng + 360, 180}, {-180, maxLng}}
+	case maxLng > 180:
+		return []LngRange{{minLng, 180}, {-180, maxLng - 360}}
+	}
+	return []LngRange{{minLng, maxLng}}
+}
+
+func validCoordinate(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SetPassword stores a bcrypt hash of password.
+func (u *Users) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.Password = string(hash)
+	return nil
+}
+
+// dummyHash is checked when there is no stored hash, so rejecting an unknown
+// account or one without a local password takes as long as rejecting a
+// wrong password. It uses the same cost as SetPassword.
+const dummyHash = "$2a$10$Fv7ljeswnBIfsvH9Myfm3OCo.FmP76MzfI.2zqmjO1aQ.pF1Jfruq"
+
+// CheckPassword reports whether password matches the stored one. Accounts
+// without a local password never match, including the zero Users.
+func (u Users) CheckPassword(password string) bool {
+	if u.Password == "" {
+		bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		return false
+	}
+	if !u.passwordHashed() {
+		return subtle.ConstantTimeCompare([]byte(u.Password), []byte(password)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
+}
+
+// PasswordNeedsRehash reports whether the stored password predates hashing
+// and should be replaced after the next successful login.
+func (u Users) PasswordNeedsRehash() bool {
+	return u.Password != "" && !u.passwordHashed()
+}
+
+func (u Users) passwordHashed() bool {
+	return strings.HasPrefix(u.Password, "$2a$") || strings.HasPrefix(u.Password, "$2b$") || strings.HasPrefix(u.Password, "$2y$")
+}
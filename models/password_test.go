@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// A cheaper dummy hash would make unknown accounts answer faster than
+// wrong passwords.
+func TestDummyHashMatchesPasswordCost(t *testing.T) {
+	var user Users
+	if err := user.SetPassword("Secret123!"); err != nil {
+		t.Fatal(err)
+	}
+	want, err := bcrypt.Cost([]byte(user.Password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bcrypt.Cost([]byte(dummyHash))
+	if err != nil {
+		t.Fatalf("dummy hash is not a bcrypt hash: %v", err)
+	}
+	if got != want {
+		t.Errorf("dummy hash cost %d, passwords are hashed at %d", got, want)
+	}
+	if (Users{}).CheckPassword("no account has this password") {
+		t.Error("an account without a password matched")
+	}
+}
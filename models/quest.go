@@ -1,15 +1,83 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Difficulty string
+
+const (
+	DifficultyEasy      Difficulty = "easy"
+	DifficultyMedium    Difficulty = "medium"
+	DifficultyHard      Difficulty = "hard"
+	DifficultyLegendary Difficulty = "legendary"
+)
+
+func (d Difficulty) Valid() bool {
+	switch d {
+	case DifficultyEasy, DifficultyMedium, DifficultyHard, DifficultyLegendary:
+		return true
+	}
+	return false
+}
+
+// QuestStatus controls whether players can see and complete a quest. Hidden
+// quests are pulled by moderators; archived quests are retired.
+type QuestStatus string
+
+const (
+	QuestActive   QuestStatus = "active"
+	QuestHidden   QuestStatus = "hidden"
+	QuestArchived QuestStatus = "archived"
+)
+
+func (s QuestStatus) Valid() bool {
+	switch s {
+	case QuestActive, QuestHidden, QuestArchived:
+		return true
+	}
+	return false
+}
 
 type Quest struct {
-	ID          uint      `json:"id" gorm:"primary_key"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Reward      int       `json:"reward"`
-	UserID      uint      `json:"user_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint        `json:"id" gorm:"primary_key"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Reward      int         `json:"reward"`
+	UserID      uint        `json:"user_id"`
+	Geofence    Geofence    `json:"geofence" gorm:"embedded;embeddedPrefix:geofence_"`
+	Difficulty  Difficulty  `json:"difficulty" gorm:"index"`
+	CategoryID  *uint       `json:"category_id" gorm:"index"`
+	Category    *Category   `json:"category,omitempty" gorm:"foreignkey:CategoryID"`
+	Tags        []Tag       `json:"tags" gorm:"many2many:quest_tags;"`
+	Status      QuestStatus `json:"status" gorm:"not null;default:active;index"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+func (q *Quest) BeforeSave(tx *gorm.DB) error {
+	q.Geofence.computeBounds()
+	if q.Status == "" {
+		q.Status = QuestActive
+	}
+	return nil
+}
+
+// Clone copies the quest's content and settings into a new, unsaved quest
+// owned by userID.
+func (q Quest) Clone(userID uint) *Quest {
+	return &Quest{
+		Title:       q.Title,
+		Description: q.Description,
+		Reward:      q.Reward,
+		UserID:      userID,
+		Geofence:    q.Geofence,
+		Difficulty:  q.Difficulty,
+		CategoryID:  q.CategoryID,
+		Tags:        append([]Tag(nil), q.Tags...),
+	}
 }
 
 type CompletedQuest struct {
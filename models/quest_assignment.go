@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AssignmentAssigned   = "assigned"
+	AssignmentInProgress = "in-progress"
+	AssignmentCompleted  = "completed"
+	AssignmentOverdue    = "overdue"
+	AssignmentExpired    = "expired"
+)
+
+type QuestAssignment struct {
+	ID                uint       `json:"id" gorm:"primary_key"`
+	QuestID           uint       `json:"quest_id" gorm:"index"`
+	Quest             Quest      `json:"quest" gorm:"foreignkey:QuestID"`
+	AssigneeID        uint       `json:"assignee_id" gorm:"index"`
+	AssignedByID      uint       `json:"assigned_by_id"`
+	DueAt             *time.Time `json:"due_at" gorm:"index"`
+	ExpireWhenOverdue bool       `json:"expire_when_overdue"`
+	Status            string     `json:"status" gorm:"index"`
+	StartedAt         *time.Time `json:"started_at"`
+	CompletedAt       *time.Time `json:"completed_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+func (a QuestAssignment) Open() bool {
+	return a.Status == AssignmentAssigned || a.Status == AssignmentInProgress || a.Status == AssignmentOverdue
+}
+
+func ValidAssignmentStatus(status string) bool {
+	switch status {
+	case AssignmentAssigned, AssignmentInProgress, AssignmentCompleted, AssignmentOverdue, AssignmentExpired:
+		return true
+	}
+	return false
+}
+
+// MarkOverdueAssignments flags open assignments whose due date has passed,
+// expiring the ones that asked for it. It returns the number of rows changed.
+func MarkOverdueAssignments(db *gorm.DB, now time.Time) (int64, error) {
+	active := []string{AssignmentAssigned, AssignmentInProgress}
+
+	expired := db.Model(&QuestAssignment{}).
+		Where("status IN ? AND due_at IS NOT NULL AND due_at < ? AND expire_when_overdue = ?", append(active, AssignmentOverdue), now, true).
+		Update("status", AssignmentExpired)
+	if expired.Error != nil {
+		return 0, expired.Error
+	}
+
+	overdue := db.Model(&QuestAssignment{}).
+		Where("status IN ? AND due_at IS NOT NULL AND due_at < ?", active, now).
+		Update("status", AssignmentOverdue)
+	if overdue.Error != nil {
+		return expired.RowsAffected, overdue.Error
+	}
+
+	return expired.RowsAffected + overdue.RowsAffected, nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+type QuestTemplate struct {
+	ID          uint       `json:"id" gorm:"primary_key"`
+	Name        string     `json:"name"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Reward      int        `json:"reward"`
+	UserID      uint       `json:"user_id"`
+	Geofence    Geofence   `json:"geofence" gorm:"embedded;embeddedPrefix:geofence_"`
+	Difficulty  Difficulty `json:"difficulty"`
+	CategoryID  *uint      `json:"category_id"`
+	Tags        []Tag      `json:"tags" gorm:"many2many:quest_template_tags;"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (t *QuestTemplate) BeforeSave(tx *gorm.DB) error {
+	t.Geofence.computeBounds()
+	return nil
+}
+
+// Variables lists the distinct placeholder names used by the template.
+func (t QuestTemplate) Variables() []string {
+	seen := map[string]bool{}
+	for _, text := range []string{t.Title, t.Description} {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			seen[match[1]] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Instantiate renders the template into a new, unsaved quest owned by userID.
+func (t QuestTemplate) Instantiate(userID uint, values map[string]string) (*Quest, error) {
+	var missing []string
+	for _, name := range t.Variables() {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+	}
+
+	render := func(text string) string {
+		return placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+			return values[placeholderPattern.FindStringSubmatch(match)[1]]
+		})
+	}
+
+	return &Quest{
+		Title:       render(t.Title),
+		Description: render(t.Description),
+		Reward:      t.Reward,
+		UserID:      userID,
+		Geofence:    t.Geofence,
+		Difficulty:  t.Difficulty,
+		CategoryID:  t.CategoryID,
+		Tags:        append([]Tag(nil), t.Tags...),
+	}, nil
+}
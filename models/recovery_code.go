@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type RecoveryCode struct {
+	ID        uint   `gorm:"primary_key"`
+	UserID    uint   `gorm:"index"`
+	CodeHash  string `gorm:"index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
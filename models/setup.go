@@ -1,31 +1,118 @@
 package models
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
+	"strings"
+	"test/config"
+	"test/migrations"
+	"time"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
-func ConnectDatabase() {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-	)
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// queryLogger is GORM's default logger, except that queries it prints for
+// errors and slow statements show placeholders instead of values, which may
+// be secrets or personal data.
+var queryLogger = logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+	SlowThreshold:        200 * time.Millisecond,
+	LogLevel:             logger.Warn,
+	Colorful:             true,
+	ParameterizedQueries: true,
+})
 
+// ConnectDatabase opens the database and, unless disabled in cfg, applies
+// pending migrations before making it available as DB.
+func ConnectDatabase(cfg config.DatabaseConfig) error {
+	database, err := OpenDatabase(cfg)
 	if err != nil {
-		panic("Failed to connect to database")
+		return err
 	}
 
-	database.AutoMigrate(&Quest{}, &Users{}, &CompletedQuest{}, &Uom{}, &Product{})
+	if cfg.AutoMigrate {
+		migrator, err := Migrator(database)
+		if err == nil {
+			_, err = migrator.Up(context.Background(), 0)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
 
 	DB = database
+	return nil
+}
+
+// OpenDatabase connects with the driver named in cfg without migrating.
+func OpenDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case "sqlite":
+		dialector = sqlite.Open(sqliteDSN(cfg.Path))
+	default:
+		dialector = postgres.Open(cfg.DSN())
+	}
+
+	database, err := gorm.Open(dialector, &gorm.Config{Logger: queryLogger, TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if cfg.Driver == "sqlite" {
+		sqlDB, err := database.DB()
+		if err != nil {
+			return nil, err
+		}
+		// SQLite allows one writer at a time, and every connection to
+		// ":memory:" would otherwise get its own empty database.
+		sqlDB.SetMaxOpenConns(1)
+	}
+	return database, nil
+}
+
+// sqliteDSN turns on foreign keys, which SQLite leaves off by default, so
+// both drivers enforce the same constraints.
+func sqliteDSN(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + "_pragma=foreign_keys(1)"
+}
+
+// Migrator returns a migrator for the schema behind database.
+func Migrator(database *gorm.DB) (*migrations.Migrator, error) {
+	sqlDB, err := database.DB()
+	if err != nil {
+		return nil, err
+	}
+	return migrations.New(sqlDB, database.Dialector.Name())
+}
+
+// PingDatabase checks that the database behind DB is reachable.
+func PingDatabase(ctx context.Context) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// CloseDatabase closes the connection pool opened by ConnectDatabase.
+func CloseDatabase() error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const MaxTagNameLength = 64
+
+var ErrTagTooLong = errors.New("tag name is too long")
+
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	Name      string    `json:"name" gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NormalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NormalizeTagNames normalizes names and drops blanks and duplicates while
+// keeping the original order.
+func NormalizeTagNames(names []string) []string {
+	var unique []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = NormalizeTagName(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		unique = append(unique, name)
+	}
+	return unique
+}
+
+func FindOrCreateTags(db *gorm.DB, names []string) ([]Tag, error) {
+	tags := []Tag{}
+	for _, name := range NormalizeTagNames(names) {
+		if len(name) > MaxTagNameLength {
+			return nil, ErrTagTooLong
+		}
+		tag := Tag{Name: name}
+		if err := db.Where(Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserIdentity links a local user to an account at an external OpenID
+// Connect provider.
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	Provider  string    `json:"provider" gorm:"uniqueIndex:idx_identity_provider_subject"`
+	Subject   string    `json:"subject" gorm:"uniqueIndex:idx_identity_provider_subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
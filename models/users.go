@@ -2,19 +2,42 @@ package models
 
 import "time"
 
+const (
+	RolePlayer  = "player"
+	RoleManager = "manager"
+	RoleAdmin   = "admin"
+	// RoleService marks non-human accounts that authenticate with API keys only.
+	RoleService = "service"
+)
+
 type Users struct {
-	ID              uint             `json:"id" gorm:"primary_key"`
-	Username        string           `json:"Username"`
-	Email           string           `json:"email"`
-	Password        string           `json:"password"`
-	Token           string           `json:"token"`
-	Point           int              `json:"point"`
-	Quests          []Quest          `json:"quests" gorm:"foreignkey:UserID"`
-	CompletedQuests []CompletedQuest `gorm:"foreignkey:UserID"`
-	CreatedAt       time.Time        `json:"created_at"`
-	UpdatedAt       time.Time        `json:"updated_at"`
+	ID                 uint             `json:"id" gorm:"primary_key"`
+	Username           string           `json:"Username"`
+	Email              string           `json:"email"`
+	Password           string           `json:"-"`
+	Token              string           `json:"token"`
+	Point              int              `json:"point"`
+	Role               string           `json:"role" gorm:"default:player"`
+	EmailVerifiedAt    *time.Time       `json:"email_verified_at"`
+	VerificationSentAt *time.Time       `json:"-"`
+	SessionVersion     uint             `json:"-" gorm:"not null;default:0"`
+	TOTPSecret         string           `json:"-"`
+	TOTPEnabled        bool             `json:"totp_enabled"`
+	TOTPLastStep       int64            `json:"-"`
+	Quests             []Quest          `json:"quests" gorm:"foreignkey:UserID"`
+	CompletedQuests    []CompletedQuest `gorm:"foreignkey:UserID"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
 }
 
 func (u *Users) AppendCompletedQuest(completeQuest CompletedQuest) {
 	u.CompletedQuests = append(u.CompletedQuests, completeQuest)
 }
+
+func (u Users) CanManageQuests() bool {
+	return u.Role == RoleManager || u.Role == RoleAdmin
+}
+
+func (u Users) IsVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
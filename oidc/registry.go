@@ -0,0 +1,52 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"test/config"
+)
+
+var providers = map[string]*Provider{}
+
+func Register(p *Provider) {
+	providers[p.Name] = p
+}
+
+func Lookup(name string) (*Provider, bool) {
+	p, ok := providers[name]
+	return p, ok
+}
+
+func Names() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Configure registers every provider in cfg. Redirect URLs are derived from
+// baseURL.
+func Configure(cfg config.OIDCConfig, baseURL string) {
+	for name, settings := range cfg.Providers {
+		Register(&Provider{
+			Name:         name,
+			Issuer:       settings.Issuer,
+			ClientID:     settings.ClientID,
+			ClientSecret: settings.ClientSecret,
+			RedirectURL:  strings.TrimRight(baseURL, "/") + "/auth/oidc/" + name + "/callback",
+			Scopes:       settings.Scopes,
+		})
+	}
+}
+
+func RandomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
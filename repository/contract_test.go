@@ -0,0 +1,239 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"test/config"
+	"test/models"
+	"test/repository"
+	"testing"
+	"time"
+)
+
+// implementations runs test against each repository implementation, so the
+// in-memory fakes keep behaving like the database they stand in for.
+func implementations(t *testing.T, test func(t *testing.T, repos repository.Repositories)) {
+	t.Run("gorm", func(t *testing.T) {
+		cfg := config.Default().Database
+		cfg.Driver = "sqlite"
+		cfg.Path = ":memory:"
+		if err := models.ConnectDatabase(cfg); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { models.CloseDatabase() })
+		test(t, repository.NewGorm(models.DB))
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, repository.NewMemory().Repositories())
+	})
+}
+
+func tagNames(tags []models.Tag) []string {
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func TestQuestRepository(t *testing.T) {
+	implementations(t, func(t *testing.T, repos repository.Repositories) {
+		ctx := context.Background()
+		owner := models.Users{Username: "alice", Email: "alice@example.com"}
+		if err := repos.Users.Create(ctx, &owner); err != nil {
+			t.Fatal(err)
+		}
+
+		tags, err := repos.Quests.ResolveTags(ctx, []string{"Outdoor", " outdoor", "dogs"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := tagNames(tags); len(names) != 2 || names[0] != "outdoor" || names[1] != "dogs" {
+			t.Fatalf("resolved tags %v, want [outdoor dogs]", names)
+		}
+
+		walk := models.Quest{Title: "Walk the dog", Description: "Around the park", Reward: 10, UserID: owner.ID, Tags: tags}
+		read := models.Quest{Title: "Read a book", Description: "Any book", Reward: 5, UserID: owner.ID, Status: models.QuestHidden}
+		for _, quest := range []*models.Quest{&walk, &read} {
+			if err := repos.Quests.Create(ctx, quest); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if walk.ID == 0 || walk.Status != models.QuestActive {
+			t.Errorf("created quest has ID %d and status %q", walk.ID, walk.Status)
+		}
+
+		got, err := repos.Quests.Get(ctx, walk.ID)
+		if err != nil || got.Title != walk.Title || len(got.Tags) != 2 {
+			t.Errorf("Get = %+v, %v", got, err)
+		}
+		if _, err := repos.Quests.GetActive(ctx, read.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetActive on a hidden quest: %v, want ErrNotFound", err)
+		}
+		if _, err := repos.Quests.Get(ctx, 999); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Get on a missing quest: %v, want ErrNotFound", err)
+		}
+
+		listed, err := repos.Quests.List(ctx, repository.QuestFilter{Tags: []string{"Dogs", "outdoor"}})
+		if err != nil || len(listed) != 1 || listed[0].ID != walk.ID {
+			t.Errorf("List by tags = %v, %v; want only the walk", listed, err)
+		}
+		listed, err = repos.Quests.List(ctx, repository.QuestFilter{Status: models.QuestHidden})
+		if err != nil || len(listed) != 1 || listed[0].ID != read.ID {
+			t.Errorf("List by status = %v, %v; want only the book", listed, err)
+		}
+
+		walk.Reward = 15
+		if err := repos.Quests.Update(ctx, &walk, nil); err != nil {
+			t.Fatal(err)
+		}
+		if walk.Reward != 15 || len(walk.Tags) != 2 {
+			t.Errorf("Update without tags: reward %d, tags %v", walk.Reward, tagNames(walk.Tags))
+		}
+		if err := repos.Quests.Update(ctx, &walk, tags[:1]); err != nil {
+			t.Fatal(err)
+		}
+		if names := tagNames(walk.Tags); len(names) != 1 || names[0] != "outdoor" {
+			t.Errorf("Update with tags: tags %v, want [outdoor]", names)
+		}
+
+		if err := repos.Quests.Delete(ctx, walk.ID); err != nil {
+			t.Fatalf("deleting a tagged quest: %v", err)
+		}
+		if _, err := repos.Quests.Get(ctx, walk.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Get after Delete: %v, want ErrNotFound", err)
+		}
+		if err := repos.Quests.Delete(ctx, walk.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("deleting twice: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestUserRepository(t *testing.T) {
+	implementations(t, func(t *testing.T, repos repository.Repositories) {
+		ctx := context.Background()
+		user := models.Users{Username: "alice", Email: "alice@example.com"}
+		if err := repos.Users.Create(ctx, &user); err != nil {
+			t.Fatal(err)
+		}
+		if user.ID == 0 || user.Role != models.RolePlayer {
+			t.Errorf("created user has ID %d and role %q", user.ID, user.Role)
+		}
+
+		if found, err := repos.Users.FindByEmail(ctx, "alice@example.com"); err != nil || found.ID != user.ID {
+			t.Errorf("FindByEmail = %d, %v", found.ID, err)
+		}
+		if _, err := repos.Users.FindByUsername(ctx, "bob"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("FindByUsername for a missing user: %v, want ErrNotFound", err)
+		}
+
+		verifiedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		if err := repos.Users.SetPassword(ctx, user.ID, "hash"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Users.SetEmailVerifiedAt(ctx, user.ID, verifiedAt); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := repos.Users.Get(ctx, user.ID)
+		if err != nil || stored.Password != "hash" || stored.EmailVerifiedAt == nil || !stored.EmailVerifiedAt.Equal(verifiedAt) {
+			t.Errorf("Get after updates = password %q, verified %v, %v", stored.Password, stored.EmailVerifiedAt, err)
+		}
+
+		quest := models.Quest{Title: "Walk the dog", Description: "Around the park", Reward: 10, UserID: user.ID}
+		if err := repos.Quests.Create(ctx, &quest); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Users.AwardQuest(ctx, &stored, quest, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Users.AwardQuest(ctx, &stored, quest, time.Now()); !errors.Is(err, repository.ErrAlreadyCompleted) {
+			t.Errorf("awarding twice: %v, want ErrAlreadyCompleted", err)
+		}
+		if done, err := repos.Users.HasCompleted(ctx, user.ID, quest.ID); err != nil || !done {
+			t.Errorf("HasCompleted = %v, %v", done, err)
+		}
+
+		profile, err := repos.Users.GetProfile(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profile.Point != 10 || len(profile.Quests) != 1 || len(profile.CompletedQuests) != 1 {
+			t.Errorf("profile has %d points, %d quests, %d completions; want 10, 1, 1",
+				profile.Point, len(profile.Quests), len(profile.CompletedQuests))
+		}
+	})
+}
+
+func TestInventoryRepositories(t *testing.T) {
+	implementations(t, func(t *testing.T, repos repository.Repositories) {
+		ctx := context.Background()
+		kg, litre := models.Uom{Name: "kg"}, models.Uom{Name: "litre"}
+		for _, uom := range []*models.Uom{&litre, &kg} {
+			if err := repos.Uoms.Create(ctx, uom); err != nil {
+				t.Fatal(err)
+			}
+		}
+		uoms, err := repos.Uoms.List(ctx)
+		if err != nil || len(uoms) != 2 || uoms[0].Name != "kg" {
+			t.Errorf("Uoms.List = %v, %v; want kg first", uoms, err)
+		}
+
+		flour := models.Product{Name: "flour", Qty: 5, UomID: kg.ID}
+		if err := repos.Products.Create(ctx, &flour); err != nil {
+			t.Fatal(err)
+		}
+		if count, err := repos.Products.CountByUom(ctx, kg.ID); err != nil || count != 1 {
+			t.Errorf("CountByUom = %d, %v; want 1", count, err)
+		}
+		products, err := repos.Products.List(ctx)
+		if err != nil || len(products) != 1 || products[0].Uom.Name != "kg" {
+			t.Errorf("Products.List = %v, %v; want flour in kg", products, err)
+		}
+
+		flour.Qty = 7
+		if err := repos.Products.Update(ctx, &flour); err != nil {
+			t.Fatal(err)
+		}
+		if stored, err := repos.Products.Get(ctx, flour.ID); err != nil || stored.Qty != 7 {
+			t.Errorf("Get after Update = %d, %v; want 7", stored.Qty, err)
+		}
+
+		if err := repos.Uoms.Delete(ctx, litre.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := repos.Uoms.Delete(ctx, litre.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("deleting a unit twice: %v, want ErrNotFound", err)
+		}
+		if err := repos.Products.Delete(ctx, flour.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Products.Get(ctx, flour.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Get after Delete: %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestTransactionRecordsAudit(t *testing.T) {
+	implementations(t, func(t *testing.T, repos repository.Repositories) {
+		ctx := context.Background()
+		admin := models.Users{Username: "root", Email: "root@example.com", Role: models.RoleAdmin}
+		if err := repos.Users.Create(ctx, &admin); err != nil {
+			t.Fatal(err)
+		}
+
+		var uom models.Uom
+		err := repos.Tx.Transaction(ctx, func(tx repository.Repositories) error {
+			uom = models.Uom{Name: "kg"}
+			if err := tx.Uoms.Create(ctx, &uom); err != nil {
+				return err
+			}
+			return tx.Audits.Record(ctx, &models.AuditLog{ActorID: admin.ID, Action: "uom.create", TargetType: models.AuditTargetUom, TargetID: uom.ID})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Uoms.Get(ctx, uom.ID); err != nil {
+			t.Errorf("unit created in a committed transaction: %v", err)
+		}
+	})
+}
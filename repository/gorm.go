@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"test/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NewGorm returns repositories backed by db.
+func NewGorm(db *gorm.DB) Repositories {
+	return Repositories{
+		Quests:   gormQuests{db},
+		Users:    gormUsers{db},
+		Uoms:     gormUoms{db},
+		Products: gormProducts{db},
+		Audits:   gormAudits{db},
+		Tx:       gormTx{db},
+	}
+}
+
+// notFound translates GORM's missing-record error into ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormQuests struct {
+	db *gorm.DB
+}
+
+func (r gormQuests) List(ctx context.Context, filter QuestFilter) ([]models.Quest, error) {
+	db := r.db.WithContext(ctx)
+	query := db.Preload("Tags").Preload("Category")
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Difficulty != "" {
+		query = query.Where("difficulty = ?", filter.Difficulty)
+	}
+	if filter.CategoryID != nil {
+		var categories []models.Category
+		if err := db.Find(&categories).Error; err != nil {
+			return nil, err
+		}
+		query = query.Where("category_id IN ?", models.DescendantIDs(categories, *filter.CategoryID))
+	}
+	if tags := models.NormalizeTagNames(filter.Tags); len(tags) > 0 {
+		query = query.Where(`id IN (?)`, db.Table("quest_tags").
+			Select("quest_tags.quest_id").
+			Joins("JOIN tags ON tags.id = quest_tags.tag_id").
+			Where("tags.name IN ?", tags).
+			Group("quest_tags.quest_id").
+			Having("COUNT(DISTINCT tags.id) = ?", len(tags)))
+	}
+
+	var quests []models.Quest
+	err := query.Find(&quests).Error
+	return quests, err
+}
+
+func (r gormQuests) ListGeofenced(ctx context.Context, bounds Bounds) ([]models.Quest, error) {
+	db := r.db.WithContext(ctx)
+	longitudes := db.Where("1 = 0")
+	for _, lng := range models.SplitLongitude(bounds.MinLng, bounds.MaxLng) {
+		longitudes = longitudes.Or("geofence_max_lng >= ? AND geofence_min_lng <= ?", lng.Min, lng.Max)
+	}
+
+	var quests []models.Quest
+	err := db.
+		Where("status = ? AND geofence_type <> ''", models.QuestActive).
+		Where("geofence_max_lat >= ? AND geofence_min_lat <= ?", bounds.MinLat, bounds.MaxLat).
+		Where(longitudes).
+		Find(&quests).Error
+	return quests, err
+}
+
+func (r gormQuests) Get(ctx context.Context, id uint) (models.Quest, error) {
+	var quest models.Quest
+	err := r.db.WithContext(ctx).Preload("Tags").Preload("Category").Where("id = ?", id).First(&quest).Error
+	return quest, notFound(err)
+}
+
+func (r gormQuests) GetActive(ctx context.Context, id uint) (models.Quest, error) {
+	var quest models.Quest
+	err := r.db.WithContext(ctx).Where("id = ? AND status = ?", id, models.QuestActive).First(&quest).Error
+	return quest, notFound(err)
+}
+
+func (r gormQuests) Create(ctx context.Context, quest *models.Quest) error {
+	return r.db.WithContext(ctx).Create(quest).Error
+}
+
+func (r gormQuests) Update(ctx context.Context, quest *models.Quest, tags []models.Tag) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Omit("Tags", "Category").Save(quest).Error; err != nil {
+		return err
+	}
+	if tags != nil {
+		if err := db.Model(quest).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+	}
+	quest.Tags, quest.Category = nil, nil
+	return db.Preload("Tags").Preload("Category").First(quest, quest.ID).Error
+}
+
+// Delete removes the quest with its tag links and assignments, which
+// reference it. Completions are kept for the users' history.
+func (r gormQuests) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("quest_id = ?", id).Delete(&models.QuestAssignment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Quest{ID: id}).Association("Tags").Clear(); err != nil {
+			return err
+		}
+		result := tx.Delete(&models.Quest{}, id)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return result.Error
+	})
+}
+
+func (r gormQuests) CategoryExists(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Category{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r gormQuests) ResolveTags(ctx context.Context, names []string) ([]models.Tag, error) {
+	return models.FindOrCreateTags(r.db.WithContext(ctx), names)
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r gormUsers) Get(ctx context.Context, id uint) (models.Users, error) {
+	var user models.Users
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error
+	return user, notFound(err)
+}
+
+func (r gormUsers) GetProfile(ctx context.Context, id uint) (models.Users, error) {
+	var user models.Users
+	err := r.db.WithContext(ctx).Preload("Quests").Preload("CompletedQuests").Where("id = ?", id).First(&user).Error
+	return user, notFound(err)
+}
+
+func (r gormUsers) FindByEmail(ctx context.Context, email string) (models.Users, error) {
+	var user models.Users
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	return user, notFound(err)
+}
+
+func (r gormUsers) FindByUsername(ctx context.Context, username string) (models.Users, error) {
+	var user models.Users
+	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	return user, notFound(err)
+}
+
+func (r gormUsers) Create(ctx context.Context, user *models.Users) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r gormUsers) SetPassword(ctx context.Context, id uint, hash string) error {
+	return r.db.WithContext(ctx).Model(&models.Users{}).Where("id = ?", id).Update("password", hash).Error
+}
+
+func (r gormUsers) SetToken(ctx context.Context, id uint, token string) error {
+	return r.db.WithContext(ctx).Model(&models.Users{}).Where("id = ?", id).Update("token", token).Error
+}
+
+func (r gormUsers) SetVerificationSentAt(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Users{}).Where("id = ?", id).Update("verification_sent_at", at).Error
+}
+
+func (r gormUsers) SetEmailVerifiedAt(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Users{}).Where("id = ?", id).Update("email_verified_at", at).Error
+}
+
+func (r gormUsers) AwardQuest(ctx context.Context, user *models.Users, quest models.Quest, at time.Time) error {
+	completion := models.CompletedQuest{UserID: user.ID, QuestID: quest.ID, CompletedAt: at}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The unique index on user and quest makes this fail for concurrent
+		// repeats that all passed an earlier HasCompleted check.
+		if err := tx.Create(&completion).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrAlreadyCompleted
+			}
+			return err
+		}
+		err := tx.Model(&models.Users{}).Where("id = ?", user.ID).
+			Update("point", gorm.Expr("point + ?", quest.Reward)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.QuestAssignment{}).
+			Where("assignee_id = ? AND quest_id = ? AND status IN ?", user.ID, quest.ID,
+				[]string{models.AssignmentAssigned, models.AssignmentInProgress, models.AssignmentOverdue}).
+			Updates(map[string]interface{}{"status": models.AssignmentCompleted, "completed_at": at}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	user.Point += quest.Reward
+	user.AppendCompletedQuest(completion)
+	return nil
+}
+
+func (r gormUsers) HasCompleted(ctx context.Context, userID, questID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.CompletedQuest{}).
+		Where("user_id = ? AND quest_id = ?", userID, questID).Count(&count).Error
+	return count > 0, err
+}
+
+type gormUoms struct {
+	db *gorm.DB
+}
+
+func (r gormUoms) List(ctx context.Context) ([]models.Uom, error) {
+	var uoms []models.Uom
+	err := r.db.WithContext(ctx).Order("name").Find(&uoms).Error
+	return uoms, err
+}
+
+func (r gormUoms) Get(ctx context.Context, id uint) (models.Uom, error) {
+	var uom models.Uom
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&uom).Error
+	return uom, notFound(err)
+}
+
+func (r gormUoms) Create(ctx context.Context, uom *models.Uom) error {
+	return r.db.WithContext(ctx).Create(uom).Error
+}
+
+func (r gormUoms) Update(ctx context.Context, uom *models.Uom) error {
+	return r.db.WithContext(ctx).Save(uom).Error
+}
+
+func (r gormUoms) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.Uom{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormProducts struct {
+	db *gorm.DB
+}
+
+func (r gormProducts) List(ctx context.Context) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.WithContext(ctx).Preload("Uom").Order("name").Find(&products).Error
+	return products, err
+}
+
+func (r gormProducts) Get(ctx context.Context, id uint) (models.Product, error) {
+	var product models.Product
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&product).Error
+	return product, notFound(err)
+}
+
+func (r gormProducts) CountByUom(ctx context.Context, uomID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Product{}).Where("uom_id = ?", uomID).Count(&count).Error
+	return count, err
+}
+
+func (r gormProducts) Create(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Omit("Uom").Create(product).Error
+}
+
+func (r gormProducts) Update(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Omit("Uom").Save(product).Error
+}
+
+func (r gormProducts) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.Product{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormAudits struct {
+	db *gorm.DB
+}
+
+func (r gormAudits) Record(ctx context.Context, entry *models.AuditLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+type gormTx struct {
+	db *gorm.DB
+}
+
+func (r gormTx) Transaction(ctx context.Context, fn func(Repositories) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewGorm(tx))
+	})
+}
@@ -0,0 +1,577 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"test/models"
+	"time"
+)
+
+// Memory keeps every record in maps so handlers can be exercised without a
+// database. The repositories it returns share one store, so a quest created
+// through Quests shows up in a user's profile and a unit created through Uoms
+// is attached to products that use it.
+type Memory struct {
+	mu          sync.Mutex
+	nextID      uint
+	quests      map[uint]models.Quest
+	users       map[uint]models.Users
+	uoms        map[uint]models.Uom
+	products    map[uint]models.Product
+	tags        map[string]models.Tag
+	categories  []models.Category
+	completions []models.CompletedQuest
+	audits      []models.AuditLog
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		quests:   map[uint]models.Quest{},
+		users:    map[uint]models.Users{},
+		uoms:     map[uint]models.Uom{},
+		products: map[uint]models.Product{},
+		tags:     map[string]models.Tag{},
+	}
+}
+
+// Repositories returns the repositories backed by m.
+func (m *Memory) Repositories() Repositories {
+	return Repositories{
+		Quests:   memoryQuests{m},
+		Users:    memoryUsers{m},
+		Uoms:     memoryUoms{m},
+		Products: memoryProducts{m},
+		Audits:   memoryAudits{m},
+		Tx:       memoryTx{m},
+	}
+}
+
+// AuditLog returns the audit entries recorded so far, oldest first.
+func (m *Memory) AuditLog() []models.AuditLog {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.AuditLog(nil), m.audits...)
+}
+
+// AddCategory stores category, assigning an ID if it has none.
+func (m *Memory) AddCategory(category models.Category) models.Category {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if category.ID == 0 {
+		category.ID = m.id()
+	}
+	m.categories = append(m.categories, category)
+	return category
+}
+
+// AddProduct stores product, assigning an ID if it has none.
+func (m *Memory) AddProduct(product models.Product) models.Product {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if product.ID == 0 {
+		product.ID = m.id()
+	}
+	m.products[product.ID] = product
+	return product
+}
+
+// id hands out IDs from one sequence for all record types, which keeps IDs
+// unique across tables and makes mix-ups visible in tests.
+func (m *Memory) id() uint {
+	m.nextID++
+	return m.nextID
+}
+
+// quest returns the stored quest with its tags and category attached.
+func (m *Memory) quest(id uint) (models.Quest, bool) {
+	quest, ok := m.quests[id]
+	if !ok {
+		return quest, false
+	}
+	quest.Tags = append([]models.Tag{}, quest.Tags...)
+	quest.Category = nil
+	if quest.CategoryID != nil {
+		for _, category := range m.categories {
+			if category.ID == *quest.CategoryID {
+				category := category
+				quest.Category = &category
+			}
+		}
+	}
+	return quest, true
+}
+
+func (m *Memory) sortedQuestIDs() []uint {
+	ids := make([]uint, 0, len(m.quests))
+	for id := range m.quests {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+type memoryQuests struct {
+	m *Memory
+}
+
+func (r memoryQuests) List(ctx context.Context, filter QuestFilter) ([]models.Quest, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var categoryIDs map[uint]bool
+	if filter.CategoryID != nil {
+		categoryIDs = map[uint]bool{}
+		for _, id := range models.DescendantIDs(r.m.categories, *filter.CategoryID) {
+			categoryIDs[id] = true
+		}
+	}
+	tags := models.NormalizeTagNames(filter.Tags)
+
+	quests := []models.Quest{}
+	for _, id := range r.m.sortedQuestIDs() {
+		quest, _ := r.m.quest(id)
+		if filter.Status != "" && quest.Status != filter.Status {
+			continue
+		}
+		if filter.Difficulty != "" && quest.Difficulty != filter.Difficulty {
+			continue
+		}
+		if categoryIDs != nil && (quest.CategoryID == nil || !categoryIDs[*quest.CategoryID]) {
+			continue
+		}
+		if !hasAllTags(quest, tags) {
+			continue
+		}
+		quests = append(quests, quest)
+	}
+	return quests, nil
+}
+
+func hasAllTags(quest models.Quest, names []string) bool {
+	for _, name := range names {
+		found := false
+		for _, tag := range quest.Tags {
+			if tag.Name == name {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (r memoryQuests) ListGeofenced(ctx context.Context, bounds Bounds) ([]models.Quest, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	quests := []models.Quest{}
+	for _, id := range r.m.sortedQuestIDs() {
+		quest := r.m.quests[id]
+		geofence := quest.Geofence
+		if quest.Status != models.QuestActive || !geofence.Enabled() {
+			continue
+		}
+		if geofence.MaxLat < bounds.MinLat || geofence.MinLat > bounds.MaxLat {
+			continue
+		}
+		for _, lng := range models.SplitLongitude(bounds.MinLng, bounds.MaxLng) {
+			if geofence.MaxLng >= lng.Min && geofence.MinLng <= lng.Max {
+				quests = append(quests, quest)
+				break
+			}
+		}
+	}
+	return quests, nil
+}
+
+func (r memoryQuests) Get(ctx context.Context, id uint) (models.Quest, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	quest, ok := r.m.quest(id)
+	if !ok {
+		return quest, ErrNotFound
+	}
+	return quest, nil
+}
+
+func (r memoryQuests) GetActive(ctx context.Context, id uint) (models.Quest, error) {
+	quest, err := r.Get(ctx, id)
+	if err == nil && quest.Status != models.QuestActive {
+		return models.Quest{}, ErrNotFound
+	}
+	return quest, err
+}
+
+func (r memoryQuests) Create(ctx context.Context, quest *models.Quest) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	quest.BeforeSave(nil)
+	quest.ID = r.m.id()
+	quest.CreatedAt = time.Now()
+	quest.UpdatedAt = quest.CreatedAt
+	r.m.quests[quest.ID] = *quest
+	return nil
+}
+
+func (r memoryQuests) Update(ctx context.Context, quest *models.Quest, tags []models.Tag) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	stored, ok := r.m.quests[quest.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if tags == nil {
+		tags = stored.Tags
+	}
+	quest.BeforeSave(nil)
+	quest.Tags = tags
+	quest.UpdatedAt = time.Now()
+	r.m.quests[quest.ID] = *quest
+
+	*quest, _ = r.m.quest(quest.ID)
+	return nil
+}
+
+func (r memoryQuests) Delete(ctx context.Context, id uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.quests[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.m.quests, id)
+	return nil
+}
+
+func (r memoryQuests) CategoryExists(ctx context.Context, id uint) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, category := range r.m.categories {
+		if category.ID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryQuests) ResolveTags(ctx context.Context, names []string) ([]models.Tag, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	tags := []models.Tag{}
+	for _, name := range models.NormalizeTagNames(names) {
+		if len(name) > models.MaxTagNameLength {
+			return nil, models.ErrTagTooLong
+		}
+		tag, ok := r.m.tags[name]
+		if !ok {
+			tag = models.Tag{ID: r.m.id(), Name: name, CreatedAt: time.Now()}
+			r.m.tags[name] = tag
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+type memoryUsers struct {
+	m *Memory
+}
+
+func (r memoryUsers) Get(ctx context.Context, id uint) (models.Users, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[id]
+	if !ok {
+		return user, ErrNotFound
+	}
+	return user, nil
+}
+
+func (r memoryUsers) GetProfile(ctx context.Context, id uint) (models.Users, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[id]
+	if !ok {
+		return user, ErrNotFound
+	}
+	user.Quests = []models.Quest{}
+	for _, questID := range r.m.sortedQuestIDs() {
+		if quest := r.m.quests[questID]; quest.UserID == id {
+			user.Quests = append(user.Quests, quest)
+		}
+	}
+	user.CompletedQuests = []models.CompletedQuest{}
+	for _, completion := range r.m.completions {
+		if completion.UserID == id {
+			user.CompletedQuests = append(user.CompletedQuests, completion)
+		}
+	}
+	return user, nil
+}
+
+func (r memoryUsers) find(match func(models.Users) bool) (models.Users, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, user := range r.m.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return models.Users{}, ErrNotFound
+}
+
+func (r memoryUsers) FindByEmail(ctx context.Context, email string) (models.Users, error) {
+	return r.find(func(user models.Users) bool { return user.Email == email })
+}
+
+func (r memoryUsers) FindByUsername(ctx context.Context, username string) (models.Users, error) {
+	return r.find(func(user models.Users) bool { return user.Username == username })
+}
+
+func (r memoryUsers) Create(ctx context.Context, user *models.Users) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user.ID = r.m.id()
+	if user.Role == "" {
+		user.Role = models.RolePlayer
+	}
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	r.m.users[user.ID] = *user
+	return nil
+}
+
+func (r memoryUsers) update(id uint, change func(*models.Users)) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	change(&user)
+	r.m.users[id] = user
+	return nil
+}
+
+func (r memoryUsers) SetPassword(ctx context.Context, id uint, hash string) error {
+	return r.update(id, func(user *models.Users) { user.Password = hash })
+}
+
+func (r memoryUsers) SetToken(ctx context.Context, id uint, token string) error {
+	return r.update(id, func(user *models.Users) { user.Token = token })
+}
+
+func (r memoryUsers) SetVerificationSentAt(ctx context.Context, id uint, at time.Time) error {
+	return r.update(id, func(user *models.Users) { user.VerificationSentAt = &at })
+}
+
+func (r memoryUsers) SetEmailVerifiedAt(ctx context.Context, id uint, at time.Time) error {
+	return r.update(id, func(user *models.Users) { user.EmailVerifiedAt = &at })
+}
+
+func (r memoryUsers) AwardQuest(ctx context.Context, user *models.Users, quest models.Quest, at time.Time) error {
+	r.m.mu.Lock()
+	stored, ok := r.m.users[user.ID]
+	if !ok {
+		r.m.mu.Unlock()
+		return ErrNotFound
+	}
+	for _, existing := range r.m.completions {
+		if existing.UserID == user.ID && existing.QuestID == quest.ID {
+			r.m.mu.Unlock()
+			return ErrAlreadyCompleted
+		}
+	}
+	completion := models.CompletedQuest{ID: r.m.id(), UserID: user.ID, QuestID: quest.ID, CompletedAt: at}
+	r.m.completions = append(r.m.completions, completion)
+	stored.Point += quest.Reward
+	r.m.users[user.ID] = stored
+	r.m.mu.Unlock()
+
+	user.Point += quest.Reward
+	user.AppendCompletedQuest(completion)
+	return nil
+}
+
+func (r memoryUsers) HasCompleted(ctx context.Context, userID, questID uint) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, completion := range r.m.completions {
+		if completion.UserID == userID && completion.QuestID == questID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryUoms struct {
+	m *Memory
+}
+
+func (r memoryUoms) List(ctx context.Context) ([]models.Uom, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	uoms := []models.Uom{}
+	for _, uom := range r.m.uoms {
+		uoms = append(uoms, uom)
+	}
+	sort.Slice(uoms, func(i, j int) bool { return uoms[i].Name < uoms[j].Name })
+	return uoms, nil
+}
+
+func (r memoryUoms) Get(ctx context.Context, id uint) (models.Uom, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	uom, ok := r.m.uoms[id]
+	if !ok {
+		return uom, ErrNotFound
+	}
+	return uom, nil
+}
+
+func (r memoryUoms) Create(ctx context.Context, uom *models.Uom) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	uom.ID = r.m.id()
+	uom.CreatedAt = time.Now()
+	uom.UpdatedAt = uom.CreatedAt
+	r.m.uoms[uom.ID] = *uom
+	return nil
+}
+
+func (r memoryUoms) Update(ctx context.Context, uom *models.Uom) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.uoms[uom.ID]; !ok {
+		return ErrNotFound
+	}
+	uom.UpdatedAt = time.Now()
+	r.m.uoms[uom.ID] = *uom
+	return nil
+}
+
+func (r memoryUoms) Delete(ctx context.Context, id uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.uoms[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.m.uoms, id)
+	return nil
+}
+
+type memoryProducts struct {
+	m *Memory
+}
+
+func (r memoryProducts) List(ctx context.Context) ([]models.Product, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	products := []models.Product{}
+	for _, product := range r.m.products {
+		product.Uom = r.m.uoms[product.UomID]
+		products = append(products, product)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Name < products[j].Name })
+	return products, nil
+}
+
+func (r memoryProducts) Get(ctx context.Context, id uint) (models.Product, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	product, ok := r.m.products[id]
+	if !ok {
+		return product, ErrNotFound
+	}
+	return product, nil
+}
+
+func (r memoryProducts) CountByUom(ctx context.Context, uomID uint) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var count int64
+	for _, product := range r.m.products {
+		if product.UomID == uomID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memoryProducts) Create(ctx context.Context, product *models.Product) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	product.ID = r.m.id()
+	product.CreatedAt = time.Now()
+	product.UpdatedAt = product.CreatedAt
+	r.m.products[product.ID] = *product
+	return nil
+}
+
+func (r memoryProducts) Update(ctx context.Context, product *models.Product) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.products[product.ID]; !ok {
+		return ErrNotFound
+	}
+	product.UpdatedAt = time.Now()
+	r.m.products[product.ID] = *product
+	return nil
+}
+
+func (r memoryProducts) Delete(ctx context.Context, id uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.products[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.m.products, id)
+	return nil
+}
+
+type memoryAudits struct {
+	m *Memory
+}
+
+func (r memoryAudits) Record(ctx context.Context, entry *models.AuditLog) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	entry.ID = r.m.id()
+	entry.CreatedAt = time.Now()
+	r.m.audits = append(r.m.audits, *entry)
+	return nil
+}
+
+// memoryTx has no rollback: writes made before fn fails are kept.
+type memoryTx struct {
+	m *Memory
+}
+
+func (r memoryTx) Transaction(ctx context.Context, fn func(Repositories) error) error {
+	return fn(r.m.Repositories())
+}
@@ -0,0 +1,114 @@
+// Package repository defines the storage interfaces the HTTP handlers depend
+// on, with a GORM implementation for production and an in-memory one for
+// tests.
+package repository
+
+import (
+	"context"
+	"errors"
+	"test/models"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("record not found")
+	// ErrAlreadyCompleted is returned by AwardQuest when the user has
+	// completed the quest before.
+	ErrAlreadyCompleted = errors.New("quest already completed")
+)
+
+// QuestFilter narrows List. Zero fields do not filter.
+type QuestFilter struct {
+	Status     models.QuestStatus
+	Difficulty models.Difficulty
+	// CategoryID matches the category and all of its descendants.
+	CategoryID *uint
+	// Tags matches quests carrying every one of the named tags.
+	Tags []string
+}
+
+// Bounds is a latitude/longitude box, as returned by models.BoundingBox. The
+// longitudes may extend past ±180 for boxes crossing the antimeridian.
+type Bounds struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+type QuestRepository interface {
+	// List returns the matching quests with their tags and category.
+	List(ctx context.Context, filter QuestFilter) ([]models.Quest, error)
+	// ListGeofenced returns active geofenced quests whose geofence bounds
+	// overlap bounds.
+	ListGeofenced(ctx context.Context, bounds Bounds) ([]models.Quest, error)
+	// Get returns the quest with its tags and category.
+	Get(ctx context.Context, id uint) (models.Quest, error)
+	GetActive(ctx context.Context, id uint) (models.Quest, error)
+	Create(ctx context.Context, quest *models.Quest) error
+	// Update saves quest and, unless tags is nil, replaces its tags. quest
+	// is reloaded with its tags and category afterwards.
+	Update(ctx context.Context, quest *models.Quest, tags []models.Tag) error
+	Delete(ctx context.Context, id uint) error
+	CategoryExists(ctx context.Context, id uint) (bool, error)
+	// ResolveTags normalizes names and returns the matching tags, creating
+	// the missing ones.
+	ResolveTags(ctx context.Context, names []string) ([]models.Tag, error)
+}
+
+type UserRepository interface {
+	Get(ctx context.Context, id uint) (models.Users, error)
+	// GetProfile returns the user with their quests and completions.
+	GetProfile(ctx context.Context, id uint) (models.Users, error)
+	FindByEmail(ctx context.Context, email string) (models.Users, error)
+	FindByUsername(ctx context.Context, username string) (models.Users, error)
+	Create(ctx context.Context, user *models.Users) error
+	SetPassword(ctx context.Context, id uint, hash string) error
+	SetToken(ctx context.Context, id uint, token string) error
+	SetVerificationSentAt(ctx context.Context, id uint, at time.Time) error
+	SetEmailVerifiedAt(ctx context.Context, id uint, at time.Time) error
+	// AwardQuest credits quest's reward to user, records the completion and
+	// closes the user's open assignments for the quest. Each user can
+	// complete a quest once; repeats fail with ErrAlreadyCompleted.
+	AwardQuest(ctx context.Context, user *models.Users, quest models.Quest, at time.Time) error
+	HasCompleted(ctx context.Context, userID, questID uint) (bool, error)
+}
+
+type UomRepository interface {
+	// List returns all units of measure ordered by name.
+	List(ctx context.Context) ([]models.Uom, error)
+	Get(ctx context.Context, id uint) (models.Uom, error)
+	Create(ctx context.Context, uom *models.Uom) error
+	Update(ctx context.Context, uom *models.Uom) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type ProductRepository interface {
+	// List returns all products with their unit, ordered by name.
+	List(ctx context.Context) ([]models.Product, error)
+	Get(ctx context.Context, id uint) (models.Product, error)
+	CountByUom(ctx context.Context, uomID uint) (int64, error)
+	Create(ctx context.Context, product *models.Product) error
+	Update(ctx context.Context, product *models.Product) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type AuditRepository interface {
+	Record(ctx context.Context, entry *models.AuditLog) error
+}
+
+// Transactor runs a group of writes that must succeed or fail together, such
+// as a change and the audit entry describing it.
+type Transactor interface {
+	// Transaction calls fn with repositories bound to one transaction, which
+	// is committed if fn returns nil and rolled back otherwise.
+	Transaction(ctx context.Context, fn func(Repositories) error) error
+}
+
+// Repositories groups one implementation of each interface.
+type Repositories struct {
+	Quests   QuestRepository
+	Users    UserRepository
+	Uoms     UomRepository
+	Products ProductRepository
+	Audits   AuditRepository
+	Tx       Transactor
+}
@@ -0,0 +1,7 @@
+// Package templates embeds the server-rendered HTML pages into the binary.
+package templates
+
+import "embed"
+
+//go:embed *.html
+var FS embed.FS
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// SignJWT signs claims with HS256 and the signer's key.
+func (s *Signer) SignJWT(claims jwt.MapClaims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.key)
+}
+
+// ParseJWT verifies a token produced by SignJWT. Tokens using any other
+// signing method are rejected.
+func (s *Signer) ParseJWT(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return s.key, nil
+	})
+}
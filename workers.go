@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"test/logging"
+	"test/models"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const overdueSweepInterval = time.Minute
+
+func runOverdueSweeper(ctx context.Context) {
+	ticker := time.NewTicker(overdueSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := models.MarkOverdueAssignments(models.DB.WithContext(ctx), time.Now())
+		if err != nil {
+			logging.Error("Overdue sweep failed", zap.Error(err))
+			continue
+		}
+		if changed > 0 {
+			logging.Info("Marked overdue assignments", zap.Int64("count", changed))
+		}
+	}
+}